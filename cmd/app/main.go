@@ -17,7 +17,20 @@ func main() {
 		return
 	}
 
-	cache.GetGlobalBlockCache(cfg.CacheSize)
+	blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+	blockCache.SetMemoryBudget(cfg.CacheBytes)
+	if cfg.Store.Path != "" {
+		store, err := cache.OpenBoltStore(cfg.Store.Path, cache.Retention{
+			Blocks: cfg.Store.RetainBlocks,
+			MaxAge: cfg.Store.RetainAge,
+		}, cfg.Store.PruneInterval)
+		if err != nil {
+			log.Logger.Errorf("Ошибка открытия хранилища блоков: %v", err)
+			return
+		}
+		defer store.Close()
+		blockCache.SetStore(store)
+	}
 
 	if err := app.Run(cfg); err != nil {
 		log.Logger.Errorf("Ошибка запуска приложения: %v", err)
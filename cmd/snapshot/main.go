@@ -0,0 +1,160 @@
+// Команда snapshot создаёт и просматривает снимки кэша блоков без запуска сервиса.
+//
+//	snapshot create -store data/blocks.db -chain-id 0x1 [-blocks N | -from N -to N] FILE
+//	snapshot inspect FILE
+//
+// create собирает снимок из постоянного хранилища блоков (сервис при этом
+// должен быть остановлен: файл хранилища открывается монопольно).
+package main
+
+import (
+	"errors"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+const _usage = `usage:
+  snapshot create -store PATH -chain-id ID [-blocks N | -from N -to N] FILE
+  snapshot inspect FILE
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, _usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = create(os.Args[2:])
+	case "inspect":
+		err = inspect(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, _usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snapshot %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	storePath := flags.String("store", "data/blocks.db", "block store file")
+	chainID := flags.String("chain-id", "", "chain id the store was filled from, e.g. 0x1")
+	blocks := flags.Int64("blocks", 10000, "number of newest blocks to include")
+	from := flags.String("from", "", "first block number (overrides -blocks)")
+	to := flags.String("to", "", "last block number (default: newest stored)")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *chainID == "" {
+		return errors.New("snapshot file and -chain-id are required")
+	}
+
+	store, err := cache.OpenBoltStore(*storePath, cache.Retention{}, 0)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	first, last, ok := store.Bounds()
+	if !ok {
+		return fmt.Errorf("block store %s is empty", *storePath)
+	}
+	if *to != "" {
+		if last, err = models.ParseBlockNumber(*to); err != nil {
+			return fmt.Errorf("-to: %w", err)
+		}
+	}
+	first = max(first, last-models.BlockNumber(*blocks)+1)
+	if *from != "" {
+		if first, err = models.ParseBlockNumber(*from); err != nil {
+			return fmt.Errorf("-from: %w", err)
+		}
+	}
+
+	var collected []*models.Block
+	err = store.Scan(first, last, func(block *models.Block) error {
+		collected = append(collected, block)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	header, err := cache.WriteSnapshot(flags.Arg(0), *chainID, collected)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d blocks, head %d %s\n", flags.Arg(0), header.Blocks, header.Head, header.HeadHash)
+	return nil
+}
+
+func inspect(args []string) error {
+	if len(args) != 1 {
+		return errors.New("snapshot file is required")
+	}
+	info, err := os.Stat(args[0])
+	if err != nil {
+		return err
+	}
+	snapshot, err := cache.OpenSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	var (
+		count, confirmed, traced, gaps int
+		txs, withdrawals               int
+		first, last                    models.BlockNumber
+	)
+	for {
+		block, err := snapshot.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		number, err := models.ParseBlockNumber(block.Number)
+		if err != nil {
+			return fmt.Errorf("block %q: %w", block.Number, err)
+		}
+		if count == 0 {
+			first = number
+		} else if number != last+1 {
+			gaps++
+		}
+		last = number
+		count++
+		txs += len(block.Transactions)
+		withdrawals += len(block.Withdrawals)
+		if block.Confirmed {
+			confirmed++
+		}
+		if block.Traced {
+			traced++
+		}
+	}
+
+	header := snapshot.Header
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "file:\t%s (%d bytes)\n", args[0], info.Size())
+	fmt.Fprintf(w, "version:\t%d\n", header.Version)
+	fmt.Fprintf(w, "chain id:\t%s\n", header.ChainID)
+	fmt.Fprintf(w, "created:\t%s\n", header.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(w, "head:\t%d %s\n", header.Head, header.HeadHash)
+	fmt.Fprintf(w, "blocks:\t%d (header says %d)\n", count, header.Blocks)
+	if count > 0 {
+		fmt.Fprintf(w, "range:\t%d..%d, %d gaps\n", first, last, gaps)
+	}
+	fmt.Fprintf(w, "confirmed:\t%d\n", confirmed)
+	fmt.Fprintf(w, "traced:\t%d\n", traced)
+	fmt.Fprintf(w, "transactions:\t%d\n", txs)
+	fmt.Fprintf(w, "withdrawals:\t%d\n", withdrawals)
+	return w.Flush()
+}
@@ -1,42 +1,223 @@
 package configs
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 )
 
+// Config — настройки приложения. CacheSize ограничивает число блоков в кэше,
+// CacheBytes — их оценочный размер в памяти (0 — без ограничения). BatchSize — начальный размер пакета блоков;
+// дальше он подстраивается в пределах [BatchSizeMin, BatchSizeMax] так, чтобы
+// ответ укладывался в BatchTargetBytes и BatchTargetLatency.
 type Config struct {
-	App                 App           `yaml:"app"`
-	GETBLOCK_API_KEY    string        `yaml:"Getblock_Api_Key" env:"GETBLOCK_API_KEY" env-required:"true"`
-	HTTPClientTimeout   time.Duration `yaml:"http_client_timeout"`
-	MaxIdleConns        int           `yaml:"max_idle_conns"`
-	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
-	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
-	CacheSize           int           `yaml:"cache_size"`
-	BlocksToAnalyze     int64         `yaml:"blocks_to_analyze"`
-	BatchSize           int64         `yaml:"batch_size"`
-	HTTP                HTTP          `yaml:"http"`
+	App                 App              `yaml:"app"`
+	HTTPClientTimeout   time.Duration    `yaml:"http_client_timeout"`
+	MaxIdleConns        int              `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost int              `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration    `yaml:"idle_conn_timeout"`
+	CacheSize           int              `yaml:"cache_size"`
+	CacheBytes          int64            `yaml:"cache_bytes" env:"CACHE_BYTES" env-default:"268435456"`
+	Store               Store            `yaml:"store"`
+	Snapshot            Snapshot         `yaml:"snapshot"`
+	BlocksToAnalyze     int64            `yaml:"blocks_to_analyze"`
+	MaxBlockRange       int64            `yaml:"max_block_range" env-default:"1000"`
+	ConfirmationDepth   int64            `yaml:"confirmation_depth" env-default:"12"`
+	CheckTimeout        time.Duration    `yaml:"check_timeout" env-default:"60s"`
+	BatchSize           int64            `yaml:"batch_size"`
+	BatchSizeMin        int64            `yaml:"batch_size_min" env-default:"1"`
+	BatchSizeMax        int64            `yaml:"batch_size_max" env-default:"50"`
+	BatchTargetBytes    int64            `yaml:"batch_target_bytes" env-default:"8388608"`
+	BatchTargetLatency  time.Duration    `yaml:"batch_target_latency" env-default:"3s"`
+	AccountingMode      string           `yaml:"accounting_mode" env-default:"tx"`
+	TraceMethod         string           `yaml:"trace_method" env-default:"debug"`
+	Logs                Logs             `yaml:"logs"`
+	HTTP                HTTP             `yaml:"http"`
+	Admin               Admin            `yaml:"admin"`
+	RPC                 RPC              `yaml:"rpc"`
+	RPCProviders        []RPC            `yaml:"rpc_providers"`
+	WS                  WS               `yaml:"ws"`
+	Retry               Retry            `yaml:"retry"`
+	RetryMethods        map[string]Retry `yaml:"retry_methods"`
+	RetryBudget         RetryBudget      `yaml:"retry_budget"`
+	CircuitBreaker      CircuitBreaker   `yaml:"circuit_breaker"`
+	Hedge               Hedge            `yaml:"hedge"`
 }
 
+// RPC описывает провайдера JSON-RPC. Основной провайдер задаётся секцией rpc,
+// резервные — списком rpc_providers; запросы распределяются между ними по здоровью.
+// URL может содержать {key} для Auth: path; для header и bearer ключ передаётся
+// в заголовке и в URL не попадает.
+// RateLimit — единиц стоимости в секунду (0 — без ограничения), RateBurst — запас
+// ведра; MethodCosts задаёт стоимость методов, остальные стоят 1.
+type RPC struct {
+	Name        string             `yaml:"name"`
+	URL         string             `yaml:"url" env:"RPC_URL" env-default:"https://go.getblock.io/{key}/"`
+	Auth        string             `yaml:"auth" env:"RPC_AUTH" env-default:"path"`
+	AuthHeader  string             `yaml:"auth_header"`
+	APIKey      string             `yaml:"api_key" env:"GETBLOCK_API_KEY"`
+	Headers     map[string]string  `yaml:"headers"`
+	RateLimit   float64            `yaml:"rate_limit" env:"RPC_RATE_LIMIT"`
+	RateBurst   int                `yaml:"rate_burst"`
+	MethodCosts map[string]float64 `yaml:"method_costs"`
+}
+
+// WS описывает WebSocket-эндпоинт для подписки на newHeads. Подписка включается,
+// если задан URL; ключ берётся из секции rpc. Пока последний полученный заголовок
+// моложе HeadMaxAge, голова цепи берётся из кэша, а неподтверждённые блоки
+// не перезапрашиваются.
+type WS struct {
+	URL        string            `yaml:"url" env:"WS_URL"`
+	Auth       string            `yaml:"auth" env:"WS_AUTH" env-default:"path"`
+	AuthHeader string            `yaml:"auth_header"`
+	Headers    map[string]string `yaml:"headers"`
+	HeadMaxAge time.Duration     `yaml:"head_max_age" env-default:"30s"`
+}
+
+// Store — постоянное хранилище блоков на диске за кэшем в памяти; включается,
+// если задан Path. Хранятся блоки не ниже самого нового сохранённого минус
+// RetainBlocks и записанные не раньше RetainAge назад (0 — без ограничения);
+// лишние удаляются раз в PruneInterval.
+type Store struct {
+	Path          string        `yaml:"path" env:"BLOCK_STORE_PATH"`
+	RetainBlocks  int64         `yaml:"retain_blocks" env-default:"100000"`
+	RetainAge     time.Duration `yaml:"retain_age"`
+	PruneInterval time.Duration `yaml:"prune_interval" env-default:"10m"`
+}
+
+// Snapshot — снимок кэша блоков: сохраняется в Path при штатной остановке
+// и загружается при старте, если снят с той же сети и его голова всё ещё
+// в канонической цепи. Пустой Path отключает снимки.
+type Snapshot struct {
+	Path string `yaml:"path" env:"CACHE_SNAPSHOT_PATH"`
+}
+
+// Logs — запросы eth_getLogs для анализа токенов: окно делится на части по
+// ChunkSize блоков, части отправляются пакетами не больше BatchSize запросов.
+type Logs struct {
+	ChunkSize int64 `yaml:"chunk_size" env-default:"20"`
+	BatchSize int   `yaml:"batch_size" env-default:"2"`
+}
+
+// Retry — политика повторов запросов к узлу. Секция retry задаёт политику по
+// умолчанию, retry_methods — переопределения для отдельных методов JSON-RPC;
+// незаданные поля переопределения берутся из retry.
+type Retry struct {
+	Attempts   int           `yaml:"attempts" env-default:"5"`
+	Delay      time.Duration `yaml:"delay" env-default:"1s"`
+	MaxDelay   time.Duration `yaml:"max_delay" env-default:"30s"`
+	MaxElapsed time.Duration `yaml:"max_elapsed" env-default:"2m"`
+	Jitter     float64       `yaml:"jitter" env-default:"0.2"`
+}
+
+// RetryBudget ограничивает повторы всех методов долей Ratio от числа запросов
+// с запасом Reserve повторов. Ratio 0 отключает ограничение.
+type RetryBudget struct {
+	Ratio   float64 `yaml:"ratio" env-default:"0.2"`
+	Reserve int     `yaml:"reserve" env-default:"10"`
+}
+
+// CircuitBreaker — предохранитель каждого провайдера: размыкается после Threshold
+// ошибок подряд и через OpenTimeout пропускает пробный запрос.
+type CircuitBreaker struct {
+	Threshold   int           `yaml:"threshold" env-default:"5"`
+	OpenTimeout time.Duration `yaml:"open_timeout" env-default:"30s"`
+}
+
+// Hedge включает дублирование медленных запросов методов Methods: если ответ
+// не пришёл за перцентиль Percentile наблюдённых задержек (но не раньше MinDelay),
+// запрос уходит второму эндпоинту и используется первый ответ.
+type Hedge struct {
+	Enabled    bool          `yaml:"enabled" env:"RPC_HEDGE"`
+	Percentile float64       `yaml:"percentile" env-default:"0.95"`
+	MinDelay   time.Duration `yaml:"min_delay" env-default:"100ms"`
+	Methods    []string      `yaml:"methods" env-default:"eth_getBlockByNumber"`
+}
+
+// RetryFor возвращает политику повторов для метода method.
+func (c *Config) RetryFor(method string) Retry {
+	retry := c.Retry
+	override, ok := c.RetryMethods[method]
+	if !ok {
+		return retry
+	}
+	if override.Attempts != 0 {
+		retry.Attempts = override.Attempts
+	}
+	if override.Delay != 0 {
+		retry.Delay = override.Delay
+	}
+	if override.MaxDelay != 0 {
+		retry.MaxDelay = override.MaxDelay
+	}
+	if override.MaxElapsed != 0 {
+		retry.MaxElapsed = override.MaxElapsed
+	}
+	if override.Jitter != 0 {
+		retry.Jitter = override.Jitter
+	}
+	return retry
+}
+
+// Режимы учёта изменений баланса.
+const (
+	AccountingModeTx    = "tx"
+	AccountingModeTrace = "trace"
+)
+
+// Методы трассировки для режима AccountingModeTrace.
+const (
+	TraceMethodDebug  = "debug"
+	TraceMethodParity = "parity"
+)
+
 type App struct {
 	Name        string `yaml:"name"`
 	Version     string `yaml:"version"`
 	Environment string `yaml:"environment"`
 }
 
+// Admin — доступ к /admin: запросы должны нести заголовок
+// Authorization: Bearer <Token>. Без Token эндпоинты /admin не регистрируются.
+type Admin struct {
+	Token string `yaml:"token" env:"ADMIN_TOKEN"`
+}
+
 type HTTP struct {
 	Port string `env-required:"true" yaml:"port" env:"HTTP_PORT"`
 }
 
 func LoadConfig(path string) (*Config, error) {
-	if err := godotenv.Load(); err != nil {
+	// Без файла .env переменные берутся из окружения процесса.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
 	cfg := &Config{}
 	if err := cleanenv.ReadConfig(path, cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
 	return cfg, nil
 }
+
+// validate отклоняет неизвестные значения перечислимых настроек: опечатка
+// не должна молча переключать сервис на режим по умолчанию.
+func (c *Config) validate() error {
+	switch c.AccountingMode {
+	case AccountingModeTx, AccountingModeTrace:
+	default:
+		return fmt.Errorf("accounting_mode: unknown value %q, want %q or %q", c.AccountingMode, AccountingModeTx, AccountingModeTrace)
+	}
+	switch c.TraceMethod {
+	case TraceMethodDebug, TraceMethodParity:
+	default:
+		return fmt.Errorf("trace_method: unknown value %q, want %q or %q", c.TraceMethod, TraceMethodDebug, TraceMethodParity)
+	}
+	return nil
+}
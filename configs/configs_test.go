@@ -0,0 +1,36 @@
+package configs
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		mode, method string
+		ok           bool
+	}{
+		{AccountingModeTx, TraceMethodDebug, true},
+		{AccountingModeTrace, TraceMethodParity, true},
+		{"traces", TraceMethodDebug, false},
+		{"", TraceMethodDebug, false},
+		{AccountingModeTrace, "geth", false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{AccountingMode: tt.mode, TraceMethod: tt.method}
+		if err := cfg.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%q, %q) = %v, want ok %v", tt.mode, tt.method, err, tt.ok)
+		}
+	}
+}
+
+// TestLoadConfigWithoutEnvFile: без .env в рабочем каталоге настройки читаются
+// из файла конфигурации и окружения процесса.
+func TestLoadConfigWithoutEnvFile(t *testing.T) {
+	t.Setenv("GETBLOCK_API_KEY", "key")
+	t.Setenv("HTTP_PORT", "9090")
+	cfg, err := LoadConfig("config.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTP.Port != "9090" {
+		t.Errorf("http port = %q, want value from the environment", cfg.HTTP.Port)
+	}
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"eth_bal/configs"
 	v1 "eth_bal/internal/contoller/http/v1"
 	"eth_bal/internal/usecase"
@@ -9,15 +10,39 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// _writeTimeoutMargin оставляет время на запись ответа после дедлайна проверки.
+	_writeTimeoutMargin = 5 * time.Second
+	// _snapshotTimeout ограничивает обращения к узлу при загрузке снимка кэша.
+	_snapshotTimeout = 30 * time.Second
+)
+
 func Run(cfg *configs.Config) error {
-	checkerUseCase := usecase.New(cfg)
+	checkerUseCase, err := usecase.New(cfg)
+	if err != nil {
+		return fmt.Errorf("app - Run - usecase.New: %w", err)
+	}
+	snapshotCtx, cancel := context.WithTimeout(context.Background(), _snapshotTimeout)
+	if err := checkerUseCase.LoadSnapshot(snapshotCtx); err != nil {
+		fmt.Printf("app - Run - LoadSnapshot: %v\n", err)
+	}
+	cancel()
+
+	ingestCtx, stopIngest := context.WithCancel(context.Background())
+	defer stopIngest()
+	go checkerUseCase.Ingest(ingestCtx)
+
 	handler := gin.New()
-	v1.NewRouter(handler, checkerUseCase)
-	httpServer := httpserver.New(handler, httpserver.Port(cfg.HTTP.Port))
+	v1.NewRouter(handler, cfg, checkerUseCase)
+	httpServer := httpserver.New(handler,
+		httpserver.Port(cfg.HTTP.Port),
+		httpserver.WriteTimeout(cfg.CheckTimeout+_writeTimeoutMargin),
+	)
 
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
@@ -29,8 +54,15 @@ func Run(cfg *configs.Config) error {
 		fmt.Printf("app - Run - httpServer.Notify: %v\n", err)
 	}
 
+	stopIngest()
 	if err := httpServer.Shutdown(); err != nil {
 		fmt.Printf("app - Run - httpServer.Shutdown: %v\n", err)
 	}
+
+	// Фоновая предзагрузка не должна менять кэш во время сохранения снимка.
+	checkerUseCase.Stop()
+	if err := checkerUseCase.SaveSnapshot(); err != nil {
+		fmt.Printf("app - Run - SaveSnapshot: %v\n", err)
+	}
 	return nil
 }
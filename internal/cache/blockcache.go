@@ -3,7 +3,12 @@ package cache
 import (
 	"eth_bal/internal/models"
 	"eth_bal/pkg/log"
+	"math"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/sirupsen/logrus"
@@ -14,18 +19,95 @@ var (
 	once             sync.Once
 )
 
+// BlockCache — кэш блоков в памяти. Ключ — номер блока models.BlockNumber,
+// а не строка провайдера; по хэшу блоки находятся через отдельный индекс.
+// Число блоков ограничено size, а их оценочный размер — бюджетом памяти:
+// при превышении вытесняются давно не использованные блоки. Если подключено
+// постоянное хранилище, кэш работает горячим уровнем перед ним: добавленные
+// блоки записываются в хранилище, а промахи по номеру дочитываются из него.
 type BlockCache struct {
 	cache *lru.Cache
+	store BlockStore
+
+	// budget — бюджет памяти в байтах (0 — без ограничения), bytes — оценка занятой.
+	budget int64
+	bytes  atomic.Int64
+
+	// Счётчики поиска по номеру: найден в памяти, в хранилище, не найден.
+	hits, storeHits, misses atomic.Uint64
+
+	// chainMu сериализует проверку связности цепочки при добавлении блоков.
+	chainMu sync.Mutex
+
+	// index: адрес -> хэш транзакции (ключ вывода со стейкинга, ключ добытого
+	// блока) -> место записи в кэше; hashes: хэш блока -> номер блока.
+	indexMu sync.RWMutex
+	index   map[string]map[string]indexRef
+	hashes  map[string]models.BlockNumber
+
+	// head — последний заголовок, полученный по подписке newHeads.
+	headMu   sync.RWMutex
+	head     models.BlockNumber
+	headHash string
+	headAt   time.Time
+}
+
+// Stats — состояние кэша блоков. First и Last — крайние номера блоков в памяти,
+// HitRatio — доля поисков по номеру, найденных в памяти.
+type Stats struct {
+	Entries      int                 `json:"entries"`
+	Bytes        int64               `json:"bytes"`
+	MemoryBudget int64               `json:"memoryBudget"`
+	First        *models.BlockNumber `json:"first,omitempty"`
+	Last         *models.BlockNumber `json:"last,omitempty"`
+	Hits         uint64              `json:"hits"`
+	StoreHits    uint64              `json:"storeHits"`
+	Misses       uint64              `json:"misses"`
+	HitRatio     float64             `json:"hitRatio"`
+	Store        bool                `json:"store"`
+}
+
+// indexRef указывает на запись в закэшированном блоке: pos — позиция транзакции
+// или вывода со стейкинга в блоке, для добытого блока — -1.
+type indexRef struct {
+	number models.BlockNumber
+	pos    int
+}
+
+// IndexedTx — транзакция из кэша вместе с блоком, в который она входит.
+type IndexedTx struct {
+	Block *models.Block
+	Tx    models.Transaction
+}
+
+// IndexedWithdrawal — вывод со стейкинга из кэша вместе с блоком.
+type IndexedWithdrawal struct {
+	Block      *models.Block
+	Withdrawal models.Withdrawal
+}
+
+// AddressActivity — записи индекса адресов в диапазоне блоков: транзакции,
+// в которых адрес участвует, выводы со стейкинга на него и блоки, где он
+// получатель комиссии.
+type AddressActivity struct {
+	Transactions []IndexedTx
+	Withdrawals  []IndexedWithdrawal
+	Mined        []*models.Block
 }
 
 func NewBlockCache(size int) (*BlockCache, error) {
 	log.Logger.WithField("cache_size", size).Info("Initializing cache")
-	cache, err := lru.New(size)
+	c := &BlockCache{
+		index:  make(map[string]map[string]indexRef),
+		hashes: make(map[string]models.BlockNumber),
+	}
+	cache, err := lru.NewWithEvict(size, c.onEvicted)
 	if err != nil {
 		log.Logger.WithError(err).Error("Failed to initialize cache")
 		return nil, err
 	}
-	return &BlockCache{cache: cache}, nil
+	c.cache = cache
+	return c, nil
 }
 
 func GetGlobalBlockCache(size int) *BlockCache {
@@ -39,35 +121,449 @@ func GetGlobalBlockCache(size int) *BlockCache {
 	return globalBlockCache
 }
 
-func (c *BlockCache) Get(blockNumber string) (*models.Block, bool) {
+// SetStore подключает постоянное хранилище. Вызывается до начала работы с кэшем.
+func (c *BlockCache) SetStore(store BlockStore) {
+	c.store = store
+}
+
+// SetMemoryBudget ограничивает оценочный размер блоков в кэше. Вызывается
+// до начала работы с кэшем; 0 снимает ограничение.
+func (c *BlockCache) SetMemoryBudget(bytes int64) {
+	c.budget = bytes
+}
+
+// GetByNumber возвращает блок по номеру, при промахе — из постоянного хранилища.
+func (c *BlockCache) GetByNumber(number models.BlockNumber) (*models.Block, bool) {
 	I := log.Logger.WithFields(logrus.Fields{
-		"block_number": blockNumber,
+		"block_number": number,
 	})
-	block, ok := c.cache.Get(blockNumber)
+	block, ok := c.cache.Get(number)
 	if !ok {
+		if stored, ok := c.load(number); ok {
+			c.storeHits.Add(1)
+			cacheLookups.WithLabelValues("store").Inc()
+			I.Debug("Block loaded from store")
+			return stored, true
+		}
+		c.misses.Add(1)
+		cacheLookups.WithLabelValues("miss").Inc()
 		I.Debug("Cache miss")
 		return nil, false
 	}
+	c.hits.Add(1)
+	cacheLookups.WithLabelValues("hit").Inc()
 	I.Debug("Cache hit")
 	return block.(*models.Block), true
 }
 
-func (c *BlockCache) Add(blockNumber string, block *models.Block) {
-	c.cache.Add(blockNumber, block)
+// GetByHash возвращает блок по хэшу. Ищутся только блоки в памяти: хранилище
+// индексировано по номеру.
+func (c *BlockCache) GetByHash(hash string) (*models.Block, bool) {
+	c.indexMu.RLock()
+	number, ok := c.hashes[strings.ToLower(hash)]
+	c.indexMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	value, ok := c.cache.Get(number)
+	if !ok || !strings.EqualFold(value.(*models.Block).Hash, hash) {
+		return nil, false
+	}
+	return value.(*models.Block), true
+}
+
+// Range возвращает имеющиеся блоки с номерами от from до to включительно
+// по возрастанию номера; промахи дочитываются из постоянного хранилища.
+func (c *BlockCache) Range(from, to models.BlockNumber) []*models.Block {
+	var blocks []*models.Block
+	for n := from; n <= to; n++ {
+		if block, ok := c.GetByNumber(n); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// load читает блок из хранилища и поднимает его в кэш. Блок, который не
+// связан по хэшам с соседями в кэше, устарел после реорганизации: он
+// удаляется из хранилища и будет загружен заново.
+func (c *BlockCache) load(number models.BlockNumber) (*models.Block, bool) {
+	if c.store == nil {
+		return nil, false
+	}
+	block, ok, err := c.store.Get(number)
+	if err != nil {
+		log.Logger.WithError(err).WithField("block_number", number).Warn("Failed to read block from store")
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	c.chainMu.Lock()
+	defer c.chainMu.Unlock()
+	if cached, ok := c.cache.Peek(number); ok {
+		return cached.(*models.Block), true
+	}
+	if parent, ok := c.peek(number - 1); ok && block.ParentHash != "" && !strings.EqualFold(parent.Hash, block.ParentHash) {
+		c.store.Delete(number)
+		return nil, false
+	}
+	if child, ok := c.peek(number + 1); ok && child.ParentHash != "" && !strings.EqualFold(child.ParentHash, block.Hash) {
+		c.store.Delete(number)
+		return nil, false
+	}
+	c.put(number, block)
+	return block, true
+}
+
+// put кладёт блок в кэш и вытесняет старые блоки сверх бюджета памяти.
+// Возвращает true, если блок заменил закэшированный блок с другим хэшем.
+// Вызывается под chainMu.
+func (c *BlockCache) put(number models.BlockNumber, block *models.Block) (replaced bool) {
+	if value, ok := c.cache.Peek(number); ok {
+		// Замена значения не вызывает onEvicted.
+		old := value.(*models.Block)
+		replaced = !strings.EqualFold(old.Hash, block.Hash)
+		c.unindexBlock(number, old)
+		c.bytes.Add(-blockSize(old))
+	}
+	c.bytes.Add(blockSize(block))
+	if c.cache.Add(number, block) {
+		cacheEvictions.WithLabelValues(_evictCapacity).Inc()
+	}
+	c.indexBlock(number, block)
+	for c.budget > 0 && c.bytes.Load() > c.budget && c.cache.Len() > 1 {
+		if _, _, ok := c.cache.RemoveOldest(); !ok {
+			break
+		}
+		cacheEvictions.WithLabelValues(_evictMemory).Inc()
+	}
+	c.report()
+	return replaced
+}
+
+func (c *BlockCache) report() {
+	cacheBytes.Set(float64(c.bytes.Load()))
+	cacheEntries.Set(float64(c.cache.Len()))
+}
+
+// Add кладёт блок в кэш под номером из block.Number и проверяет его связь с соседними
+// закэшированными блоками. Если parentHash не совпадает с хэшем предыдущего блока
+// (или следующий блок ссылается на другой хэш), соседний блок и примыкающие к нему
+// неподтверждённые блоки считаются осиротевшими после реорганизации и удаляются.
+// Возвращает номера осиротевших блоков, в том числе номер самого блока, если он
+// заменил в кэше блок с другим хэшем: транзакции прежнего блока тоже устарели.
+func (c *BlockCache) Add(block *models.Block) []models.BlockNumber {
+	return c.add(block, true)
+}
+
+// Restore добавляет блок из снимка так же, как Add, но не записывает его
+// в постоянное хранилище.
+func (c *BlockCache) Restore(block *models.Block) []models.BlockNumber {
+	return c.add(block, false)
+}
+
+func (c *BlockCache) add(block *models.Block, persist bool) []models.BlockNumber {
+	number, err := models.ParseBlockNumber(block.Number)
+	if err != nil {
+		log.Logger.WithError(err).WithField("block_number", block.Number).Warn("Block with invalid number not cached")
+		return nil
+	}
+
+	c.chainMu.Lock()
+	defer c.chainMu.Unlock()
+
+	replaced := c.put(number, block)
+	if persist && c.store != nil {
+		c.store.Put(number, block)
+	}
 	log.Logger.WithFields(logrus.Fields{
-		"block_number": blockNumber,
+		"block_number": number,
 	}).Debug("Block added to cache")
+
+	var orphaned []models.BlockNumber
+	if replaced {
+		cacheEvictions.WithLabelValues(_evictOrphaned).Inc()
+		orphaned = append(orphaned, number)
+	}
+	if parent, ok := c.peek(number - 1); ok && block.ParentHash != "" && !strings.EqualFold(parent.Hash, block.ParentHash) {
+		orphaned = append(orphaned, c.evictChain(number-1, -1)...)
+	}
+	if child, ok := c.peek(number + 1); ok && child.ParentHash != "" && !strings.EqualFold(child.ParentHash, block.Hash) {
+		orphaned = append(orphaned, c.evictChain(number+1, 1)...)
+	}
+	if len(orphaned) > 0 {
+		log.Logger.WithFields(logrus.Fields{
+			"block_number": number,
+			"block_hash":   block.Hash,
+			"orphaned":     orphaned,
+		}).Warn("Chain reorganization detected, orphaned blocks evicted")
+	}
+	return orphaned
+}
+
+// evictChain удаляет блок number из кэша и хранилища и продолжает в направлении
+// step, пока встречаются неподтверждённые блоки.
+func (c *BlockCache) evictChain(number models.BlockNumber, step models.BlockNumber) []models.BlockNumber {
+	var evicted []models.BlockNumber
+	for first := true; ; first = false {
+		block, ok := c.peek(number)
+		if !ok || (!first && block.Confirmed) {
+			c.report()
+			return evicted
+		}
+		c.cache.Remove(number)
+		cacheEvictions.WithLabelValues(_evictOrphaned).Inc()
+		if c.store != nil {
+			c.store.Delete(number)
+		}
+		evicted = append(evicted, number)
+		number += step
+	}
+}
+
+func (c *BlockCache) peek(number models.BlockNumber) (*models.Block, bool) {
+	if number < 0 {
+		return nil, false
+	}
+	value, ok := c.cache.Peek(number)
+	if !ok {
+		return nil, false
+	}
+	return value.(*models.Block), true
+}
+
+// SetHead запоминает голову цепи, полученную по подписке.
+func (c *BlockCache) SetHead(number models.BlockNumber, hash string) {
+	c.headMu.Lock()
+	defer c.headMu.Unlock()
+	c.head, c.headHash, c.headAt = number, hash, time.Now()
+}
+
+// Head возвращает голову цепи из подписки, если она обновлялась не позднее maxAge назад.
+func (c *BlockCache) Head(maxAge time.Duration) (models.BlockNumber, string, bool) {
+	c.headMu.RLock()
+	defer c.headMu.RUnlock()
+	if c.headAt.IsZero() || time.Since(c.headAt) > maxAge {
+		return 0, "", false
+	}
+	return c.head, c.headHash, true
+}
+
+// Purge удаляет из памяти все блоки, а с withStore — и из постоянного хранилища.
+// Возвращает число удалённых из памяти блоков.
+func (c *BlockCache) Purge(withStore bool) int {
+	c.chainMu.Lock()
+	defer c.chainMu.Unlock()
+	n := c.cache.Len()
+	c.cache.Purge()
+	cacheEvictions.WithLabelValues(_evictAdmin).Add(float64(n))
+	if withStore && c.store != nil {
+		c.store.DeleteRange(0, math.MaxInt64)
+	}
+	c.report()
+	return n
+}
+
+// EvictRange удаляет из памяти блоки с номерами от from до to, а с withStore —
+// и из постоянного хранилища. Возвращает число удалённых из памяти блоков.
+func (c *BlockCache) EvictRange(from, to models.BlockNumber, withStore bool) int {
+	c.chainMu.Lock()
+	defer c.chainMu.Unlock()
+	n := 0
+	for _, key := range c.cache.Keys() {
+		if number := key.(models.BlockNumber); number >= from && number <= to && c.cache.Remove(key) {
+			n++
+		}
+	}
+	cacheEvictions.WithLabelValues(_evictAdmin).Add(float64(n))
+	if withStore && c.store != nil {
+		c.store.DeleteRange(from, to)
+	}
+	c.report()
+	return n
+}
+
+// Stats возвращает размер, диапазон номеров, память и попадания кэша.
+func (c *BlockCache) Stats() Stats {
+	stats := Stats{
+		Entries:      c.Size(),
+		Bytes:        c.Bytes(),
+		MemoryBudget: c.budget,
+		Hits:         c.hits.Load(),
+		StoreHits:    c.storeHits.Load(),
+		Misses:       c.misses.Load(),
+		Store:        c.store != nil,
+	}
+	if keys := c.Keys(); len(keys) > 0 {
+		stats.First, stats.Last = &keys[0], &keys[len(keys)-1]
+	}
+	if lookups := stats.Hits + stats.StoreHits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
 }
 
 func (c *BlockCache) Size() int {
 	return c.cache.Len()
 }
 
-func (c *BlockCache) Keys() []string {
+// Keys возвращает номера блоков в памяти по возрастанию.
+func (c *BlockCache) Keys() []models.BlockNumber {
 	keys := c.cache.Keys()
-	stringKeys := make([]string, len(keys))
+	numbers := make([]models.BlockNumber, len(keys))
 	for i, key := range keys {
-		stringKeys[i] = key.(string)
+		numbers[i] = key.(models.BlockNumber)
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+// Blocks возвращает все блоки в памяти без изменения их давности в LRU.
+func (c *BlockCache) Blocks() []*models.Block {
+	keys := c.cache.Keys()
+	blocks := make([]*models.Block, 0, len(keys))
+	for _, key := range keys {
+		if value, ok := c.cache.Peek(key); ok {
+			blocks = append(blocks, value.(*models.Block))
+		}
+	}
+	return blocks
+}
+
+// Bytes возвращает оценку памяти, занятой блоками в кэше.
+func (c *BlockCache) Bytes() int64 {
+	return c.bytes.Load()
+}
+
+func (c *BlockCache) onEvicted(key interface{}, value interface{}) {
+	c.unindexBlock(key.(models.BlockNumber), value.(*models.Block))
+	c.bytes.Add(-blockSize(value.(*models.Block)))
+	log.Logger.WithField("block_number", key).Debug("Block evicted from cache")
+}
+
+// AddressActivity возвращает по индексу адресов записи адреса в блоках from..to.
+// Индекс охватывает только блоки в памяти, поэтому ok ложно, если хотя бы одного
+// блока диапазона там нет: история по такому индексу была бы неполной.
+func (c *BlockCache) AddressActivity(address string, from, to models.BlockNumber) (activity AddressActivity, ok bool) {
+	c.indexMu.RLock()
+	refs := make(map[string]indexRef, len(c.index[strings.ToLower(address)]))
+	for key, ref := range c.index[strings.ToLower(address)] {
+		if ref.number >= from && ref.number <= to {
+			refs[key] = ref
+		}
+	}
+	c.indexMu.RUnlock()
+
+	for key, ref := range refs {
+		block, found := c.peek(ref.number)
+		if !found {
+			return AddressActivity{}, false
+		}
+		// Блок могли заменить после чтения индекса: ссылка проверяется по ключу.
+		switch {
+		case ref.pos < 0:
+			activity.Mined = append(activity.Mined, block)
+		case strings.HasPrefix(key, _withdrawalKeyPrefix):
+			if ref.pos >= len(block.Withdrawals) || WithdrawalKey(block.Withdrawals[ref.pos].Index) != key {
+				return AddressActivity{}, false
+			}
+			activity.Withdrawals = append(activity.Withdrawals, IndexedWithdrawal{Block: block, Withdrawal: block.Withdrawals[ref.pos]})
+		default:
+			if ref.pos >= len(block.Transactions) || !strings.EqualFold(block.Transactions[ref.pos].Hash, key) {
+				return AddressActivity{}, false
+			}
+			activity.Transactions = append(activity.Transactions, IndexedTx{Block: block, Tx: block.Transactions[ref.pos]})
+		}
+	}
+	for n := from; n <= to; n++ {
+		if !c.cache.Contains(n) {
+			return AddressActivity{}, false
+		}
+	}
+	return activity, true
+}
+
+func (c *BlockCache) indexBlock(number models.BlockNumber, block *models.Block) {
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+	add := func(address, key string, pos int) {
+		if address == "" {
+			return
+		}
+		refs, ok := c.index[address]
+		if !ok {
+			refs = make(map[string]indexRef)
+			c.index[address] = refs
+		}
+		refs[key] = indexRef{number: number, pos: pos}
+	}
+	for i, tx := range block.Transactions {
+		for _, address := range txAddresses(tx) {
+			add(address, strings.ToLower(tx.Hash), i)
+		}
+	}
+	for i, w := range block.Withdrawals {
+		add(strings.ToLower(w.Address), WithdrawalKey(w.Index), i)
+	}
+	add(strings.ToLower(block.Miner), minedKey(number), -1)
+	if block.Hash != "" {
+		c.hashes[strings.ToLower(block.Hash)] = number
+	}
+}
+
+func (c *BlockCache) unindexBlock(number models.BlockNumber, block *models.Block) {
+	c.indexMu.Lock()
+	defer c.indexMu.Unlock()
+	// Ссылки, которые уже указывают на другой блок (та же транзакция после
+	// реорганизации), не трогаем.
+	remove := func(address, key string) {
+		if ref, ok := c.index[address][key]; !ok || ref.number != number {
+			return
+		}
+		delete(c.index[address], key)
+		if len(c.index[address]) == 0 {
+			delete(c.index, address)
+		}
+	}
+	for _, tx := range block.Transactions {
+		for _, address := range txAddresses(tx) {
+			remove(address, strings.ToLower(tx.Hash))
+		}
+	}
+	for _, w := range block.Withdrawals {
+		remove(strings.ToLower(w.Address), WithdrawalKey(w.Index))
+	}
+	remove(strings.ToLower(block.Miner), minedKey(number))
+	// Хэш, который уже указывает на другой номер, не трогаем.
+	hash := strings.ToLower(block.Hash)
+	if n, ok := c.hashes[hash]; ok && n == number {
+		delete(c.hashes, hash)
+	}
+}
+
+const _withdrawalKeyPrefix = "withdrawal:"
+
+// WithdrawalKey — ключ вывода со стейкинга в индексах и наборах, где хранятся и хэши транзакций.
+func WithdrawalKey(index string) string {
+	return _withdrawalKeyPrefix + index
+}
+
+// minedKey — ключ добытого блока в индексе адресов получателя комиссии.
+func minedKey(number models.BlockNumber) string {
+	return "mined:" + number.Hex()
+}
+
+// txAddresses возвращает адреса, участвующие в транзакции: отправителя,
+// получателя (или созданный контракт) и стороны внутренних переводов.
+func txAddresses(tx models.Transaction) []string {
+	addresses := []string{strings.ToLower(tx.From)}
+	if recipient := tx.Recipient(); recipient != "" {
+		addresses = append(addresses, strings.ToLower(recipient))
+	}
+	for _, transfer := range tx.InternalTransfers {
+		addresses = append(addresses, strings.ToLower(transfer.From), strings.ToLower(transfer.To))
 	}
-	return stringKeys
+	return addresses
 }
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"eth_bal/internal/models"
+	"slices"
+	"testing"
+)
+
+func testBlock(number models.BlockNumber, hash, parent string) *models.Block {
+	return &models.Block{Number: number.Hex(), Hash: hash, ParentHash: parent}
+}
+
+func TestBlockCacheAddReorg(t *testing.T) {
+	tests := []struct {
+		name     string
+		add      *models.Block
+		orphaned []models.BlockNumber
+		// hash — хэш блока 12 в кэше после Add, пустой — блока нет.
+		hash string
+	}{
+		{
+			name: "same block again",
+			add:  testBlock(12, "0xc", "0xb"),
+			hash: "0xc",
+		},
+		{
+			name:     "head replaced at the same height",
+			add:      testBlock(12, "0xc2", "0xb"),
+			orphaned: []models.BlockNumber{12},
+			hash:     "0xc2",
+		},
+		{
+			name: "head replaced on a new parent",
+			add:  testBlock(12, "0xc2", "0xb2"),
+			// Неподтверждённые блоки ниже тоже считаются осиротевшими.
+			orphaned: []models.BlockNumber{10, 11, 12},
+			hash:     "0xc2",
+		},
+		{
+			name:     "middle block replaced",
+			add:      testBlock(11, "0xb2", "0xa"),
+			orphaned: []models.BlockNumber{11, 12},
+		},
+		{
+			name: "new head on top",
+			add:  testBlock(13, "0xd", "0xc"),
+			hash: "0xc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewBlockCache(16)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Add(testBlock(10, "0xa", "0x9"))
+			c.Add(testBlock(11, "0xb", "0xa"))
+			c.Add(testBlock(12, "0xc", "0xb"))
+
+			orphaned := c.Add(tt.add)
+			slices.Sort(orphaned)
+			if !slices.Equal(orphaned, tt.orphaned) {
+				t.Errorf("orphaned = %v, want %v", orphaned, tt.orphaned)
+			}
+			hash := ""
+			if block, ok := c.GetByNumber(12); ok {
+				hash = block.Hash
+			}
+			if hash != tt.hash {
+				t.Errorf("block 12 hash = %q, want %q", hash, tt.hash)
+			}
+		})
+	}
+}
+
+func TestBlockCacheAddressIndex(t *testing.T) {
+	const (
+		alice = "0x00000000000000000000000000000000000000a1"
+		bob   = "0x00000000000000000000000000000000000000b2"
+		miner = "0x00000000000000000000000000000000000000ee"
+	)
+	block := func(number models.BlockNumber, hash, parent string, txs ...models.Transaction) *models.Block {
+		b := testBlock(number, hash, parent)
+		b.Miner, b.Transactions = miner, txs
+		return b
+	}
+	tx := func(hash, from, to string) models.Transaction {
+		return models.Transaction{Hash: hash, From: from, To: to}
+	}
+	newCache := func(t *testing.T, size int) *BlockCache {
+		t.Helper()
+		c, err := NewBlockCache(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Add(block(10, "0xa", "0x9", tx("0x01", alice, bob)))
+		c.Add(block(11, "0xb", "0xa", tx("0x02", bob, alice)))
+		c.Add(&models.Block{Number: "0xc", Hash: "0xc", ParentHash: "0xb", Miner: miner,
+			Withdrawals: []models.Withdrawal{{Index: "0x7", Address: alice, Amount: "0x1"}}})
+		return c
+	}
+	hashes := func(activity AddressActivity) []string {
+		var got []string
+		for _, indexed := range activity.Transactions {
+			got = append(got, indexed.Tx.Hash)
+		}
+		slices.Sort(got)
+		return got
+	}
+
+	t.Run("lookup", func(t *testing.T) {
+		c := newCache(t, 16)
+		activity, ok := c.AddressActivity(alice, 10, 12)
+		if !ok || !slices.Equal(hashes(activity), []string{"0x01", "0x02"}) || len(activity.Withdrawals) != 1 {
+			t.Fatalf("alice 10..12: ok %v, txs %v, withdrawals %d", ok, hashes(activity), len(activity.Withdrawals))
+		}
+		activity, _ = c.AddressActivity(alice, 11, 11)
+		if !slices.Equal(hashes(activity), []string{"0x02"}) || len(activity.Withdrawals) != 0 {
+			t.Errorf("alice 11..11: txs %v, withdrawals %d", hashes(activity), len(activity.Withdrawals))
+		}
+		if activity, _ := c.AddressActivity(miner, 10, 12); len(activity.Mined) != 3 {
+			t.Errorf("miner: %d mined blocks, want 3", len(activity.Mined))
+		}
+		if _, ok := c.AddressActivity(alice, 10, 13); ok {
+			t.Error("range with a block outside the cache reported as covered")
+		}
+	})
+
+	t.Run("replaced block is unindexed", func(t *testing.T) {
+		c := newCache(t, 16)
+		c.Add(block(11, "0xb2", "0xa", tx("0x03", bob, miner)))
+		activity, _ := c.AddressActivity(alice, 10, 11)
+		if got := hashes(activity); !slices.Equal(got, []string{"0x01"}) {
+			t.Errorf("alice after reorg: txs %v, want [0x01]", got)
+		}
+	})
+
+	t.Run("evictions prune the index", func(t *testing.T) {
+		c := newCache(t, 16)
+		c.EvictRange(10, 11, false)
+		if activity, _ := c.AddressActivity(bob, 0, 100); len(activity.Transactions) != 0 {
+			t.Errorf("bob after EvictRange: %v", hashes(activity))
+		}
+		c.Purge(false)
+		if len(c.index) != 0 || len(c.hashes) != 0 {
+			t.Errorf("index after Purge: %d addresses, %d hashes", len(c.index), len(c.hashes))
+		}
+	})
+
+	t.Run("capacity eviction prunes the index", func(t *testing.T) {
+		c := newCache(t, 2)
+		if _, ok := c.index[bob]["0x01"]; ok {
+			t.Error("tx of the evicted block 10 is still indexed")
+		}
+		if activity, _ := c.AddressActivity(bob, 11, 11); !slices.Equal(hashes(activity), []string{"0x02"}) {
+			t.Errorf("bob 11..11: %v", hashes(activity))
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"eth_bal/internal/models"
+	"eth_bal/pkg/log"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// _storeQueue — длина очереди записей; при заполнении Put и Delete ждут диск.
+	_storeQueue = 1024
+	// _storeBatch — сколько записей из очереди попадает в одну транзакцию.
+	_storeBatch = 256
+)
+
+var (
+	blocksBucket   = []byte("blocks")
+	storedAtBucket = []byte("stored_at")
+)
+
+// BoltStore — BlockStore в файле bbolt. Ключи — номер блока в big-endian, поэтому
+// блоки в файле упорядочены по номеру. Записи копятся в очереди и сбрасываются
+// одной транзакцией в отдельной горутине, там же по таймеру применяется Retention.
+type BoltStore struct {
+	db        *bolt.DB
+	retention Retention
+
+	mu     sync.RWMutex
+	closed bool
+	writes chan storeWrite
+	done   chan struct{}
+}
+
+// storeWrite — отложенная запись; block == nil означает удаление блоков
+// с номерами от number до to.
+type storeWrite struct {
+	number models.BlockNumber
+	to     models.BlockNumber
+	block  *models.Block
+}
+
+// storedBlock сохраняет вместе с блоком признаки, которые не входят в его JSON.
+type storedBlock struct {
+	*models.Block
+	Traced    bool `json:"traced,omitempty"`
+	Confirmed bool `json:"confirmed,omitempty"`
+}
+
+// OpenBoltStore открывает (или создаёт) хранилище в файле path. Retention
+// применяется сразу после открытия и затем раз в pruneInterval.
+func OpenBoltStore(path string, retention Retention, pruneInterval time.Duration) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("block store %s: %w", path, err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("block store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, storedAtBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("block store %s: %w", path, err)
+	}
+	s := &BoltStore{
+		db:        db,
+		retention: retention,
+		writes:    make(chan storeWrite, _storeQueue),
+		done:      make(chan struct{}),
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"path":   path,
+		"blocks": s.Len(),
+	}).Info("Block store opened")
+	s.prune()
+	go s.run(pruneInterval)
+	return s, nil
+}
+
+func (s *BoltStore) Get(number models.BlockNumber) (*models.Block, bool, error) {
+	var stored storedBlock
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(blocksBucket).Get(storeKey(number))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &stored)
+	})
+	if err != nil || !found || stored.Block == nil {
+		return nil, false, err
+	}
+	block := stored.Block
+	block.Traced, block.Confirmed = stored.Traced, stored.Confirmed
+	return block, true, nil
+}
+
+// Put ставит блок в очередь на запись. Блок не должен изменяться после вызова.
+func (s *BoltStore) Put(number models.BlockNumber, block *models.Block) {
+	s.enqueue(storeWrite{number: number, block: block})
+}
+
+// Delete ставит в очередь удаление блока.
+func (s *BoltStore) Delete(number models.BlockNumber) {
+	s.enqueue(storeWrite{number: number, to: number})
+}
+
+// DeleteRange ставит в очередь удаление блоков с номерами от from до to.
+func (s *BoltStore) DeleteRange(from, to models.BlockNumber) {
+	if from > to {
+		return
+	}
+	s.enqueue(storeWrite{number: from, to: to})
+}
+
+func (s *BoltStore) enqueue(w storeWrite) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed || w.number < 0 {
+		return
+	}
+	s.writes <- w
+}
+
+// Bounds возвращает наименьший и наибольший номер блока в хранилище.
+func (s *BoltStore) Bounds() (first, last models.BlockNumber, ok bool) {
+	s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(blocksBucket).Cursor()
+		k, _ := c.First()
+		if k == nil {
+			return nil
+		}
+		first, ok = models.BlockNumber(binary.BigEndian.Uint64(k)), true
+		k, _ = c.Last()
+		last = models.BlockNumber(binary.BigEndian.Uint64(k))
+		return nil
+	})
+	return first, last, ok
+}
+
+// Scan вызывает fn для сохранённых блоков с номерами от from до to по возрастанию.
+func (s *BoltStore) Scan(from, to models.BlockNumber, fn func(*models.Block) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(blocksBucket).Cursor()
+		end := storeKey(to)
+		for k, v := c.Seek(storeKey(from)); k != nil && string(k) <= string(end); k, v = c.Next() {
+			var stored storedBlock
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return fmt.Errorf("block %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			if stored.Block == nil {
+				continue
+			}
+			stored.Block.Traced, stored.Block.Confirmed = stored.Traced, stored.Confirmed
+			if err := fn(stored.Block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Len возвращает число блоков в хранилище.
+func (s *BoltStore) Len() int {
+	n := 0
+	s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(blocksBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// Close дописывает очередь на диск и закрывает файл.
+func (s *BoltStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.writes)
+	s.mu.Unlock()
+	<-s.done
+	return s.db.Close()
+}
+
+func (s *BoltStore) run(pruneInterval time.Duration) {
+	defer close(s.done)
+	var tick <-chan time.Time
+	if pruneInterval > 0 && (s.retention.Blocks > 0 || s.retention.MaxAge > 0) {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case w, ok := <-s.writes:
+			if !ok {
+				return
+			}
+			s.flush(w)
+		case <-tick:
+			s.prune()
+		}
+	}
+}
+
+// flush записывает w и всё, что успело накопиться в очереди, одной транзакцией.
+func (s *BoltStore) flush(w storeWrite) {
+	batch := []storeWrite{w}
+drain:
+	for len(batch) < _storeBatch {
+		select {
+		case next, ok := <-s.writes:
+			if !ok {
+				break drain
+			}
+			batch = append(batch, next)
+		default:
+			break drain
+		}
+	}
+	storedAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(storedAt, uint64(time.Now().Unix()))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		blocks, times := tx.Bucket(blocksBucket), tx.Bucket(storedAtBucket)
+		for _, w := range batch {
+			key := storeKey(w.number)
+			if w.block == nil {
+				if err := deleteRange(blocks, times, key, storeKey(w.to)); err != nil {
+					return err
+				}
+				continue
+			}
+			data, err := json.Marshal(storedBlock{Block: w.block, Traced: w.block.Traced, Confirmed: w.block.Confirmed})
+			if err != nil {
+				return err
+			}
+			if err := blocks.Put(key, data); err != nil {
+				return err
+			}
+			if err := times.Put(key, storedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Logger.WithError(err).WithField("writes", len(batch)).Error("Failed to write blocks to store")
+	}
+}
+
+// prune удаляет блоки, вышедшие за Retention.
+func (s *BoltStore) prune() {
+	if s.retention.Blocks <= 0 && s.retention.MaxAge <= 0 {
+		return
+	}
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		blocks, times := tx.Bucket(blocksBucket), tx.Bucket(storedAtBucket)
+		var stale [][]byte
+		if s.retention.Blocks > 0 {
+			c := blocks.Cursor()
+			if last, _ := c.Last(); last != nil {
+				floor := storeKey(models.BlockNumber(int64(binary.BigEndian.Uint64(last)) - s.retention.Blocks + 1))
+				for k, _ := c.First(); k != nil && string(k) < string(floor); k, _ = c.Next() {
+					stale = append(stale, append([]byte(nil), k...))
+				}
+			}
+		}
+		if s.retention.MaxAge > 0 {
+			cutoff := uint64(time.Now().Add(-s.retention.MaxAge).Unix())
+			times.ForEach(func(k, v []byte) error {
+				if binary.BigEndian.Uint64(v) < cutoff {
+					stale = append(stale, append([]byte(nil), k...))
+				}
+				return nil
+			})
+		}
+		// Ключи копируются при сборе: память курсора нельзя использовать после удаления.
+		for _, key := range stale {
+			if blocks.Get(key) == nil {
+				continue
+			}
+			if err := blocks.Delete(key); err != nil {
+				return err
+			}
+			if err := times.Delete(key); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Logger.WithError(err).Error("Failed to prune block store")
+		return
+	}
+	if removed > 0 {
+		log.Logger.WithField("removed", removed).Info("Block store pruned")
+	}
+}
+
+// deleteRange удаляет из blocks и times ключи от from до to включительно.
+func deleteRange(blocks, times *bolt.Bucket, from, to []byte) error {
+	if bytes.Equal(from, to) {
+		if err := blocks.Delete(from); err != nil {
+			return err
+		}
+		return times.Delete(from)
+	}
+	var keys [][]byte
+	c := blocks.Cursor()
+	for k, _ := c.Seek(from); k != nil && bytes.Compare(k, to) <= 0; k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, key := range keys {
+		if err := blocks.Delete(key); err != nil {
+			return err
+		}
+		if err := times.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func storeKey(number models.BlockNumber) []byte {
+	if number < 0 {
+		number = 0
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(number))
+	return key
+}
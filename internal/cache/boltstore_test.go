@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"encoding/binary"
+	"eth_bal/internal/models"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestStore(t *testing.T, path string, retention Retention) *BoltStore {
+	t.Helper()
+	s, err := OpenBoltStore(path, retention, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// reopen закрывает хранилище, дописав очередь, и открывает файл заново.
+func reopen(t *testing.T, s *BoltStore, path string, retention Retention) *BoltStore {
+	t.Helper()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return openTestStore(t, path, retention)
+}
+
+func storedNumbers(t *testing.T, s *BoltStore) []models.BlockNumber {
+	t.Helper()
+	var numbers []models.BlockNumber
+	err := s.Scan(0, 1<<40, func(block *models.Block) error {
+		n, err := models.ParseBlockNumber(block.Number)
+		numbers = append(numbers, n)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return numbers
+}
+
+func putRange(s *BoltStore, from, to models.BlockNumber) {
+	for n := from; n <= to; n++ {
+		s.Put(n, testBlock(n, n.Hex(), ""))
+	}
+}
+
+func TestBoltStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	s := openTestStore(t, path, Retention{})
+	block := &models.Block{
+		Number: "0x7", Hash: "0x77", ParentHash: "0x66", Miner: "0xee", Traced: true, Confirmed: true,
+		Transactions: []models.Transaction{{Hash: "0x01", From: "0xa1", To: "0xb2", Value: "0x64", Status: models.TxStatusSuccess,
+			InternalTransfers: []models.Transfer{{From: "0xb2", To: "0xc3", Value: "0x1"}}}},
+		Withdrawals: []models.Withdrawal{{Index: "0x1", Address: "0xb2", Amount: "0x2"}},
+	}
+	s.Put(7, block)
+	s = reopen(t, s, path, Retention{})
+
+	got, ok, err := s.Get(7)
+	if err != nil || !ok {
+		t.Fatalf("Get(7) = %v, %v", ok, err)
+	}
+	if !got.Traced || !got.Confirmed {
+		t.Errorf("traced %v, confirmed %v after round-trip, want both set", got.Traced, got.Confirmed)
+	}
+	if got.Hash != block.Hash || len(got.Transactions) != 1 || len(got.Transactions[0].InternalTransfers) != 1 || len(got.Withdrawals) != 1 {
+		t.Errorf("block changed in the store: %+v", got)
+	}
+	if _, ok, err := s.Get(8); ok || err != nil {
+		t.Errorf("Get(8) = %v, %v, want a miss", ok, err)
+	}
+}
+
+// TestBoltStoreCloseFlushesQueue: Close дописывает все записи очереди, а не только первую пачку.
+func TestBoltStoreCloseFlushesQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	s := openTestStore(t, path, Retention{})
+	const n = 3 * _storeBatch
+	putRange(s, 1, n)
+	s = reopen(t, s, path, Retention{})
+	if got := s.Len(); got != n {
+		t.Fatalf("%d blocks after Close, want %d", got, n)
+	}
+	if first, last, ok := s.Bounds(); !ok || first != 1 || last != n {
+		t.Errorf("bounds %d..%d (%v), want 1..%d", first, last, ok, n)
+	}
+	// После Close записи молча отбрасываются.
+	s.Close()
+	s.Put(n+1, testBlock(n+1, "0xff", ""))
+}
+
+func TestBoltStoreDeleteRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	s := openTestStore(t, path, Retention{})
+	putRange(s, 1, 8)
+	s.DeleteRange(3, 5)
+	s.Delete(8)
+	s.DeleteRange(7, 6) // пустой диапазон ничего не удаляет
+	s = reopen(t, s, path, Retention{})
+	if got, want := storedNumbers(t, s), []models.BlockNumber{1, 2, 6, 7}; !slices.Equal(got, want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+}
+
+func TestBoltStorePrune(t *testing.T) {
+	t.Run("by count", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blocks.db")
+		s := openTestStore(t, path, Retention{})
+		putRange(s, 1, 10)
+		// Retention применяется при открытии.
+		s = reopen(t, s, path, Retention{Blocks: 3})
+		if got, want := storedNumbers(t, s), []models.BlockNumber{8, 9, 10}; !slices.Equal(got, want) {
+			t.Errorf("stored %v, want %v", got, want)
+		}
+	})
+	t.Run("by age", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "blocks.db")
+		retention := Retention{MaxAge: time.Hour}
+		s := openTestStore(t, path, retention)
+		putRange(s, 1, 4)
+		s = reopen(t, s, path, retention)
+		// Блоки 1 и 2 записаны два часа назад.
+		old := make([]byte, 8)
+		binary.BigEndian.PutUint64(old, uint64(time.Now().Add(-2*time.Hour).Unix()))
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			for _, n := range []models.BlockNumber{1, 2} {
+				if err := tx.Bucket(storedAtBucket).Put(storeKey(n), old); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.prune()
+		if got, want := storedNumbers(t, s), []models.BlockNumber{3, 4}; !slices.Equal(got, want) {
+			t.Errorf("stored %v, want %v", got, want)
+		}
+	})
+}
+
+func TestBlockCacheStoreFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.db")
+	s := openTestStore(t, path, Retention{})
+	s.Put(7, testBlock(7, "0x7", "0x6"))
+	s.Put(9, testBlock(9, "0x9", "0x8"))
+	s = reopen(t, s, path, Retention{})
+
+	c, err := NewBlockCache(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetStore(s)
+	// Блок 10 в памяти ссылается на другого родителя: сохранённый блок 9 устарел.
+	c.Add(testBlock(10, "0xa", "0x9b"))
+
+	block, ok := c.GetByNumber(7)
+	if !ok || block.Hash != "0x7" {
+		t.Fatalf("GetByNumber(7) = %v, %v, want the stored block", block, ok)
+	}
+	if _, ok := c.cache.Peek(models.BlockNumber(7)); !ok {
+		t.Error("block loaded from store is not kept in memory")
+	}
+	if stats := c.Stats(); stats.StoreHits != 1 || stats.Misses != 0 {
+		t.Errorf("stats: %d store hits, %d misses, want 1 and 0", stats.StoreHits, stats.Misses)
+	}
+
+	if _, ok := c.GetByNumber(9); ok {
+		t.Fatal("block orphaned by the cached child was served from store")
+	}
+	if _, ok := c.GetByNumber(8); ok {
+		t.Fatal("block missing from memory and store was found")
+	}
+	s = reopen(t, s, path, Retention{})
+	if _, ok, _ := s.Get(9); ok {
+		t.Error("orphaned block was not deleted from store")
+	}
+}
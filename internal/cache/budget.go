@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"eth_bal/internal/models"
+	"unsafe"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Причины вытеснения блоков из кэша.
+const (
+	_evictCapacity = "capacity"
+	_evictMemory   = "memory"
+	_evictOrphaned = "orphaned"
+	_evictAdmin    = "admin"
+)
+
+const (
+	_blockBytes      = int64(unsafe.Sizeof(models.Block{}))
+	_txBytes         = int64(unsafe.Sizeof(models.Transaction{}))
+	_transferBytes   = int64(unsafe.Sizeof(models.Transfer{}))
+	_withdrawalBytes = int64(unsafe.Sizeof(models.Withdrawal{}))
+	// _indexRefBytes — примерная цена одной ссылки в индексах: запись во вложенной
+	// карте и доля самой карты адреса (у большинства адресов в блоке одна-две
+	// транзакции).
+	_indexRefBytes = 256
+)
+
+var (
+	cacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eth_bal_block_cache_bytes",
+		Help: "Estimated memory retained by blocks in the cache.",
+	})
+	cacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eth_bal_block_cache_entries",
+		Help: "Blocks in the cache.",
+	})
+	cacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eth_bal_block_cache_evictions_total",
+		Help: "Blocks evicted from the cache by reason: capacity, memory, orphaned or admin.",
+	}, []string{"reason"})
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eth_bal_block_cache_lookups_total",
+		Help: "Block lookups by number: hit (memory), store (loaded from the block store) or miss.",
+	}, []string{"result"})
+)
+
+// blockSize оценивает память, которую удерживает блок в кэше: сами структуры,
+// содержимое строк и ссылки на блок в индексах хэшей и адресов.
+func blockSize(b *models.Block) int64 {
+	size := _blockBytes + 2*_indexRefBytes + strLen(b.Number, b.Hash, b.ParentHash, b.Miner, b.BaseFeePerGas, b.GasUsed)
+	for i := range b.Transactions {
+		tx := &b.Transactions[i]
+		size += _txBytes + 2*_indexRefBytes + strLen(tx.Hash, tx.From, tx.To, tx.Value, tx.Gas, tx.GasPrice,
+			tx.BlockNumber, tx.TransactionIndex, tx.GasUsed, tx.EffectiveGasPrice, tx.Status, tx.ContractAddress)
+		for _, t := range tx.InternalTransfers {
+			size += _transferBytes + 2*_indexRefBytes + strLen(t.From, t.To, t.Value)
+		}
+	}
+	for _, w := range b.Withdrawals {
+		size += _withdrawalBytes + _indexRefBytes + strLen(w.Index, w.ValidatorIndex, w.Address, w.Amount)
+	}
+	return size
+}
+
+// strLen суммирует длины строк, округляя каждую до класса размера аллокатора.
+func strLen(values ...string) int64 {
+	var n int64
+	for _, v := range values {
+		switch {
+		case len(v) == 0:
+		case len(v) <= 32:
+			n += int64(len(v)+7) &^ 7
+		default:
+			n += int64(len(v)+15) &^ 15
+		}
+	}
+	return n
+}
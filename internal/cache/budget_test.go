@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"eth_bal/internal/models"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricValue возвращает текущее значение счётчика или датчика.
+func metricValue(t *testing.T, metric prometheus.Metric) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metric.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Counter != nil {
+		return m.Counter.GetValue()
+	}
+	return m.Gauge.GetValue()
+}
+
+// sizedBlock — блок number с txs одинаковыми транзакциями.
+func sizedBlock(number models.BlockNumber, txs int) *models.Block {
+	block := testBlock(number, "0x"+strconv.Itoa(int(number)), "")
+	for i := 0; i < txs; i++ {
+		block.Transactions = append(block.Transactions, models.Transaction{
+			Hash: "0x" + strconv.Itoa(i), From: "0x00000000000000000000000000000000000000a1",
+			To: "0x00000000000000000000000000000000000000b2", Value: "0x64", GasUsed: "0x5208",
+		})
+	}
+	return block
+}
+
+func TestBlockSize(t *testing.T) {
+	empty := blockSize(sizedBlock(1, 0))
+	perTx := blockSize(sizedBlock(1, 1)) - empty
+	if empty <= 0 || perTx <= 0 {
+		t.Fatalf("empty block %d bytes, transaction %d bytes", empty, perTx)
+	}
+	for _, txs := range []int{10, 100, 1000} {
+		if got, want := blockSize(sizedBlock(1, txs)), empty+int64(txs)*perTx; got != want {
+			t.Errorf("%d transactions: %d bytes, want %d", txs, got, want)
+		}
+	}
+
+	withTransfers := sizedBlock(1, 1)
+	withTransfers.Transactions[0].InternalTransfers = []models.Transfer{{From: "0xa1", To: "0xb2", Value: "0x1"}}
+	if blockSize(withTransfers) <= empty+perTx {
+		t.Error("internal transfers are not counted")
+	}
+	withWithdrawals := sizedBlock(1, 0)
+	withWithdrawals.Withdrawals = []models.Withdrawal{{Index: "0x1", Address: "0xb2", Amount: "0x2"}}
+	if blockSize(withWithdrawals) <= empty {
+		t.Error("withdrawals are not counted")
+	}
+}
+
+func TestBlockCacheMemoryBudget(t *testing.T) {
+	c, err := NewBlockCache(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := blockSize(sizedBlock(1, 50))
+	budget := 3*size + size/2
+	c.SetMemoryBudget(budget)
+	evicted := metricValue(t, cacheEvictions.WithLabelValues(_evictMemory))
+
+	for n := models.BlockNumber(1); n <= 10; n++ {
+		c.Add(sizedBlock(n, 50))
+		if c.Bytes() > budget {
+			t.Fatalf("after block %d: %d bytes over budget %d", n, c.Bytes(), budget)
+		}
+	}
+	if got := c.Keys(); len(got) != 3 || got[0] != 8 {
+		t.Errorf("cached blocks %v, want the 3 newest", got)
+	}
+	if c.Bytes() != 3*size {
+		t.Errorf("%d bytes for 3 blocks of %d", c.Bytes(), size)
+	}
+	if d := metricValue(t, cacheEvictions.WithLabelValues(_evictMemory)) - evicted; d != 7 {
+		t.Errorf("memory evictions +%v, want +7", d)
+	}
+
+	// Блок больше бюджета остаётся единственным, а не вытесняет сам себя.
+	c.Add(sizedBlock(11, 500))
+	if got := c.Keys(); len(got) != 1 || got[0] != 11 {
+		t.Errorf("cached blocks %v after an oversized block, want [11]", got)
+	}
+}
+
+func TestBlockCacheMetrics(t *testing.T) {
+	c, err := NewBlockCache(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGauges := func(step string) {
+		t.Helper()
+		if got := metricValue(t, cacheBytes); got != float64(c.Bytes()) {
+			t.Errorf("%s: bytes gauge %v, cache %d", step, got, c.Bytes())
+		}
+		if got := metricValue(t, cacheEntries); got != float64(c.Size()) {
+			t.Errorf("%s: entries gauge %v, cache %d", step, got, c.Size())
+		}
+	}
+	evictions := func(reason string) float64 {
+		return metricValue(t, cacheEvictions.WithLabelValues(reason))
+	}
+	capacity, admin := evictions(_evictCapacity), evictions(_evictAdmin)
+
+	for n := models.BlockNumber(1); n <= 4; n++ {
+		c.Add(sizedBlock(n, int(n)))
+	}
+	assertGauges("add")
+	if d := evictions(_evictCapacity) - capacity; d != 1 {
+		t.Errorf("capacity evictions +%v, want +1", d)
+	}
+	want := blockSize(sizedBlock(2, 2)) + blockSize(sizedBlock(3, 3)) + blockSize(sizedBlock(4, 4))
+	if c.Bytes() != want {
+		t.Errorf("%d bytes after capacity eviction, want %d", c.Bytes(), want)
+	}
+
+	if n := c.EvictRange(2, 3, false); n != 2 {
+		t.Fatalf("EvictRange removed %d blocks, want 2", n)
+	}
+	assertGauges("evict range")
+	if c.Bytes() != blockSize(sizedBlock(4, 4)) {
+		t.Errorf("%d bytes after EvictRange, want %d", c.Bytes(), blockSize(sizedBlock(4, 4)))
+	}
+
+	if n := c.Purge(false); n != 1 {
+		t.Fatalf("Purge removed %d blocks, want 1", n)
+	}
+	assertGauges("purge")
+	if c.Bytes() != 0 {
+		t.Errorf("%d bytes after Purge", c.Bytes())
+	}
+	if d := evictions(_evictAdmin) - admin; d != 3 {
+		t.Errorf("admin evictions +%v, want +3", d)
+	}
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"eth_bal/internal/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// _snapshotVersion — версия формата снимка; снимки другой версии не читаются.
+const _snapshotVersion = 1
+
+// ErrSnapshotMismatch — снимок снят с другой сети или его голова больше не в канонической цепи.
+var ErrSnapshotMismatch = errors.New("snapshot does not match the chain")
+
+// SnapshotHeader — заголовок снимка кэша. Head и HeadHash — самый новый блок снимка.
+type SnapshotHeader struct {
+	Version   int                `json:"version"`
+	ChainID   string             `json:"chainId"`
+	Head      models.BlockNumber `json:"head"`
+	HeadHash  string             `json:"headHash"`
+	Blocks    int                `json:"blocks"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// Snapshot читает снимок кэша: gzip-поток JSON-значений, заголовок и за ним
+// блоки по возрастанию номера.
+type Snapshot struct {
+	Header SnapshotHeader
+
+	file *os.File
+	gz   *gzip.Reader
+	dec  *json.Decoder
+}
+
+// OpenSnapshot открывает снимок и читает его заголовок.
+func OpenSnapshot(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	s := &Snapshot{file: file, gz: gz, dec: json.NewDecoder(gz)}
+	if err := s.dec.Decode(&s.Header); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("snapshot %s: header: %w", path, err)
+	}
+	if s.Header.Version != _snapshotVersion {
+		s.Close()
+		return nil, fmt.Errorf("snapshot %s: unsupported version %d", path, s.Header.Version)
+	}
+	return s, nil
+}
+
+// Next возвращает следующий блок снимка или io.EOF после последнего.
+func (s *Snapshot) Next() (*models.Block, error) {
+	var stored storedBlock
+	if err := s.dec.Decode(&stored); err != nil {
+		return nil, err
+	}
+	if stored.Block == nil {
+		return nil, errors.New("snapshot: empty block record")
+	}
+	block := stored.Block
+	block.Traced, block.Confirmed = stored.Traced, stored.Confirmed
+	return block, nil
+}
+
+func (s *Snapshot) Close() error {
+	s.gz.Close()
+	return s.file.Close()
+}
+
+// WriteSnapshot записывает блоки в файл path снимком сети chainID. Файл
+// заменяется целиком: снимок пишется во временный файл рядом и переименовывается.
+func WriteSnapshot(path, chainID string, blocks []*models.Block) (SnapshotHeader, error) {
+	header := SnapshotHeader{Version: _snapshotVersion, ChainID: chainID, CreatedAt: time.Now().UTC()}
+	numbers := make(map[*models.Block]models.BlockNumber, len(blocks))
+	sorted := make([]*models.Block, 0, len(blocks))
+	for _, block := range blocks {
+		number, err := models.ParseBlockNumber(block.Number)
+		if err != nil {
+			continue
+		}
+		numbers[block] = number
+		sorted = append(sorted, block)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return numbers[sorted[i]] < numbers[sorted[j]] })
+	header.Blocks = len(sorted)
+	if len(sorted) > 0 {
+		head := sorted[len(sorted)-1]
+		header.Head, header.HeadHash = numbers[head], head.Hash
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return header, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return header, err
+	}
+	defer os.Remove(tmp.Name())
+	buf := bufio.NewWriter(tmp)
+	gz := gzip.NewWriter(buf)
+	enc := json.NewEncoder(gz)
+	err = enc.Encode(header)
+	for _, block := range sorted {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(storedBlock{Block: block, Traced: block.Traced, Confirmed: block.Confirmed})
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return header, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	return header, os.Rename(tmp.Name(), path)
+}
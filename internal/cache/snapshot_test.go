@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"eth_bal/internal/models"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots", "cache.snapshot.gz")
+	confirmed := testBlock(10, "0xa", "0x9")
+	confirmed.Confirmed, confirmed.Traced = true, true
+	blocks := []*models.Block{
+		testBlock(12, "0xc", "0xb"),
+		confirmed,
+		{Number: "latest"}, // блок без номера в снимок не попадает
+		testBlock(11, "0xb", "0xa"),
+	}
+	header, err := WriteSnapshot(path, "0x1", blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Blocks != 3 || header.Head != 12 || header.HeadHash != "0xc" {
+		t.Errorf("written header %+v, want 3 blocks up to 12 (0xc)", header)
+	}
+	// Временный файл переименован, рядом ничего не осталось.
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files next to the snapshot, want 1", len(entries))
+	}
+
+	snapshot, err := OpenSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	if got := snapshot.Header; got.ChainID != "0x1" || got.Head != 12 || got.HeadHash != "0xc" || got.Blocks != 3 || got.Version != _snapshotVersion {
+		t.Errorf("read header %+v", got)
+	}
+	var hashes []string
+	for {
+		block, err := snapshot.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, block.Hash)
+		if block.Hash == "0xa" && (!block.Confirmed || !block.Traced) {
+			t.Errorf("block 10: confirmed %v, traced %v, want both set", block.Confirmed, block.Traced)
+		}
+	}
+	if want := []string{"0xa", "0xb", "0xc"}; !slices.Equal(hashes, want) {
+		t.Errorf("blocks %v, want %v in ascending order", hashes, want)
+	}
+}
+
+func TestOpenSnapshotRejects(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenSnapshot(filepath.Join(dir, "missing.gz")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: %v, want fs.ErrNotExist", err)
+	}
+
+	notGzip := filepath.Join(dir, "plain.json")
+	if err := os.WriteFile(notGzip, []byte(`{"version":1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSnapshot(notGzip); err == nil {
+		t.Error("plain JSON accepted as a snapshot")
+	}
+
+	future := filepath.Join(dir, "future.gz")
+	file, err := os.Create(future)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	if err := json.NewEncoder(gz).Encode(SnapshotHeader{Version: _snapshotVersion + 1, ChainID: "0x1"}); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	file.Close()
+	if _, err := OpenSnapshot(future); err == nil {
+		t.Error("snapshot of an unknown version accepted")
+	}
+}
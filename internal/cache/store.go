@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"eth_bal/internal/models"
+	"time"
+)
+
+// BlockStore — постоянное хранилище блоков за кэшем в памяти. Ключ — номер блока.
+// Put и Delete применяются в порядке вызова, но могут записываться на диск
+// с задержкой; ошибки записи хранилище журналирует само.
+type BlockStore interface {
+	Get(number models.BlockNumber) (*models.Block, bool, error)
+	Put(number models.BlockNumber, block *models.Block)
+	Delete(number models.BlockNumber)
+	DeleteRange(from, to models.BlockNumber)
+	Close() error
+}
+
+// Retention ограничивает хранилище: Blocks — блоки не ниже самого нового
+// сохранённого минус Blocks, MaxAge — блоки, записанные не раньше MaxAge назад.
+// Нулевое значение отключает соответствующее ограничение.
+type Retention struct {
+	Blocks int64
+	MaxAge time.Duration
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"eth_bal/internal/models"
+	"eth_bal/pkg/log"
+	"strings"
+	"sync"
+)
+
+var (
+	globalTokenCache *TokenCache
+	tokenOnce        sync.Once
+)
+
+// TokenCache хранит метаданные токенов (символ, decimals). Они не меняются,
+// поэтому записи не вытесняются.
+type TokenCache struct {
+	tokens sync.Map
+}
+
+func GetGlobalTokenCache() *TokenCache {
+	tokenOnce.Do(func() {
+		globalTokenCache = &TokenCache{}
+	})
+	return globalTokenCache
+}
+
+func (c *TokenCache) Get(token string) (models.TokenInfo, bool) {
+	info, ok := c.tokens.Load(strings.ToLower(token))
+	if !ok {
+		return models.TokenInfo{}, false
+	}
+	return info.(models.TokenInfo), true
+}
+
+func (c *TokenCache) Add(info models.TokenInfo) {
+	c.tokens.Store(strings.ToLower(info.Address), info)
+	log.Logger.WithField("token", info.Address).Debug("Token info added to cache")
+}
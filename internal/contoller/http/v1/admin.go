@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/models"
+	"eth_bal/internal/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminAuth пропускает только запросы с заголовком Authorization: Bearer <token>.
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// newCacheRoutes — администрирование кэша блоков: состояние, очистка, удаление
+// диапазона и фоновая предзагрузка. Параметр store=true распространяет удаление
+// на постоянное хранилище.
+func newCacheRoutes(router *gin.RouterGroup, cfg *configs.Config, t usecase.CheckBlock) {
+	router.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, t.CacheStatus())
+	})
+
+	router.DELETE("", func(c *gin.Context) {
+		withStore, err := parseStoreFlag(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"evicted": t.PurgeCache(withStore)})
+	})
+
+	router.DELETE("/blocks", func(c *gin.Context) {
+		from, errFrom := models.ParseBlockNumber(c.Query("from"))
+		to, errTo := models.ParseBlockNumber(c.Query("to"))
+		if errFrom != nil || errTo != nil || from > to {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be block numbers with from <= to"})
+			return
+		}
+		withStore, err := parseStoreFlag(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"evicted": t.EvictBlocks(from, to, withStore)})
+	})
+
+	router.POST("/prefetch", func(c *gin.Context) {
+		params, err := parseCheckParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx, cancel, err := requestContext(c, cfg)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer cancel()
+		status, err := t.Prefetch(ctx, params)
+		if errors.Is(err, usecase.ErrPrefetchRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "prefetch": status})
+			return
+		}
+		if err != nil {
+			errorResponse(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, status)
+	})
+}
+
+func parseStoreFlag(c *gin.Context) (bool, error) {
+	withStore, err := strconv.ParseBool(c.DefaultQuery("store", "false"))
+	if err != nil {
+		return false, errors.New("store must be true or false")
+	}
+	return withStore, nil
+}
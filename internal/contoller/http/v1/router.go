@@ -1,9 +1,17 @@
 package v1
 
 import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/models"
 	"eth_bal/internal/usecase"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
 	"eth_bal/pkg/log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -11,30 +19,270 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func NewRouter(handler *gin.Engine, t usecase.CheckBlock) {
+const (
+	_defaultTopN = 10
+	_maxTopN     = 1000
+
+	_statusOK          = "ok"
+	_statusDegraded    = "degraded"
+	_statusUnavailable = "unavailable"
+)
+
+func NewRouter(handler *gin.Engine, cfg *configs.Config, t usecase.CheckBlock) {
 	handler.Use(gin.Logger())
 	handler.Use(gin.Recovery())
 
 	swaggerHandler := ginSwagger.DisablingWrapHandler(swaggerFiles.Handler, "DISABLE_SWAGGER_HTTP_HANDLE")
 	handler.GET("/swagger/*any", swaggerHandler)
 
-	handler.GET("/healthz", func(c *gin.Context) {
-		c.Status(http.StatusOK)
-	})
+	handler.GET("/healthz", healthz(t))
+	handler.GET("/readyz", readyz(t))
 
 	handler.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	api := handler.Group("/v1")
 	{
-		newEthCheckRoutes(api, t)
+		newEthCheckRoutes(api, cfg, t)
+	}
+
+	if cfg.Admin.Token == "" {
+		log.Logger.Warn("admin.token не задан, /admin отключён")
+		return
+	}
+	admin := handler.Group("/admin", adminAuth(cfg.Admin.Token))
+	{
+		newAdminRoutes(admin, cfg, t)
+	}
+}
+
+// healthz отчитывается о предохранителях провайдеров и бюджете повторов.
+// Процесс жив, пока отвечает, поэтому статус ответа всегда 200, а состояние
+// провайдеров — в теле: degraded — часть предохранителей разомкнута,
+// unavailable — разомкнуты все.
+func healthz(t usecase.CheckBlock) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, providers := providersStatus(t)
+		c.JSON(http.StatusOK, gin.H{
+			"status":      status,
+			"providers":   providers,
+			"retryBudget": t.RetryBudget(),
+		})
+	}
+}
+
+// readyz отвечает 503, пока предохранители всех провайдеров разомкнуты:
+// запросы к /v1 в это время всё равно завершатся ошибкой.
+func readyz(t usecase.CheckBlock) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, providers := providersStatus(t)
+		code := http.StatusOK
+		if status == _statusUnavailable {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{"status": status, "providers": providers})
+	}
+}
+
+// providersStatus сводит состояние предохранителей провайдеров в общий статус.
+func providersStatus(t usecase.CheckBlock) (string, []gin.H) {
+	providers := t.Providers()
+	breakers := make([]gin.H, len(providers))
+	open := 0
+	for i, p := range providers {
+		if p.Breaker == jsonrpc.BreakerOpen {
+			open++
+		}
+		breakers[i] = gin.H{"name": p.Name, "breaker": p.Breaker, "trips": p.BreakerTrips}
+	}
+	switch {
+	case open == len(providers):
+		return _statusUnavailable, breakers
+	case open > 0:
+		return _statusDegraded, breakers
 	}
+	return _statusOK, breakers
+}
+
+func newAdminRoutes(router *gin.RouterGroup, cfg *configs.Config, t usecase.CheckBlock) {
+	router.GET("/providers", func(c *gin.Context) {
+		c.JSON(http.StatusOK, t.Providers())
+	})
+	newCacheRoutes(router.Group("/cache"), cfg, t)
 }
 
-func newEthCheckRoutes(router *gin.RouterGroup, t usecase.CheckBlock) {
+func newEthCheckRoutes(router *gin.RouterGroup, cfg *configs.Config, t usecase.CheckBlock) {
 	router.GET("/check", func(c *gin.Context) {
-		result := t.Check()
+		params, err := parseCheckParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx, cancel, err := requestContext(c, cfg)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer cancel()
+		if token := c.Query("token"); token != "" {
+			if !util.IsHexAddress(token) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token address"})
+				return
+			}
+			result, err := t.CheckToken(ctx, params, token)
+			if err != nil {
+				errorResponse(c, err)
+				return
+			}
+			log.Logger.WithField("result", result).Info("Sending response")
+			c.JSON(http.StatusOK, result)
+			return
+		}
+		result, err := t.Check(ctx, params)
+		if err != nil {
+			errorResponse(c, err)
+			return
+		}
 		log.Logger.WithField("result", result).Info("Sending response")
 		c.JSON(http.StatusOK, result)
 	})
 
+	router.GET("/top", func(c *gin.Context) {
+		params, err := parseCheckParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx, cancel, err := requestContext(c, cfg)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer cancel()
+		n, err := strconv.Atoi(c.DefaultQuery("n", strconv.Itoa(_defaultTopN)))
+		if err != nil || n <= 0 || n > _maxTopN {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "n must be between 1 and " + strconv.Itoa(_maxTopN)})
+			return
+		}
+		direction := c.DefaultQuery("direction", models.DirectionAbs)
+		switch direction {
+		case models.DirectionIncrease, models.DirectionDecrease, models.DirectionAbs:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be increase, decrease or abs"})
+			return
+		}
+		result, err := t.Top(ctx, params, n, direction)
+		if err != nil {
+			errorResponse(c, err)
+			return
+		}
+		log.Logger.WithField("entries", len(result)).Info("Sending response")
+		c.JSON(http.StatusOK, result)
+	})
+
+	router.GET("/address/:addr/changes", func(c *gin.Context) {
+		address := c.Param("addr")
+		if !util.IsHexAddress(address) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address"})
+			return
+		}
+		params, err := parseCheckParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx, cancel, err := requestContext(c, cfg)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer cancel()
+		result, err := t.AddressChanges(ctx, params, address)
+		if err != nil {
+			errorResponse(c, err)
+			return
+		}
+		log.Logger.WithField("transfers", len(result.Transfers)).Info("Sending response")
+		c.JSON(http.StatusOK, result)
+	})
+
+	router.GET("/tokens/top", func(c *gin.Context) {
+		params, err := parseCheckParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx, cancel, err := requestContext(c, cfg)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer cancel()
+		result, err := t.TopTokens(ctx, params)
+		if err != nil {
+			errorResponse(c, err)
+			return
+		}
+		log.Logger.WithField("tokens", len(result)).Info("Sending response")
+		c.JSON(http.StatusOK, result)
+	})
+}
+
+// parseCheckParams читает окно анализа из query-параметров from, to и blocks.
+func parseCheckParams(c *gin.Context) (models.CheckParams, error) {
+	params := models.CheckParams{
+		From: c.Query("from"),
+		To:   c.Query("to"),
+	}
+	if blocks := c.Query("blocks"); blocks != "" {
+		n, err := strconv.ParseInt(blocks, 10, 64)
+		if err != nil || n <= 0 {
+			return params, errors.New("blocks must be a positive integer")
+		}
+		params.Blocks = n
+	}
+	return params, nil
+}
+
+// requestContext возвращает контекст запроса с дедлайном: из параметра timeout,
+// но не больше cfg.CheckTimeout. Контекст отменяется и при отключении клиента.
+func requestContext(c *gin.Context, cfg *configs.Config) (context.Context, context.CancelFunc, error) {
+	timeout := cfg.CheckTimeout
+	if raw := c.Query("timeout"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, nil, errors.New("timeout must be a positive duration, e.g. 30s")
+		}
+		if timeout <= 0 || d < timeout {
+			timeout = d
+		}
+	}
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	return ctx, cancel, nil
+}
+
+func errorResponse(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	var incomplete *usecase.IncompleteWindowError
+	switch {
+	case errors.As(err, &incomplete):
+		// Узел не отдал часть блоков окна: результат с пропусками не возвращается.
+		log.Logger.WithError(err).Warn("Request failed")
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "missingBlocks": incomplete.Missing})
+		return
+	case errors.Is(err, usecase.ErrInvalidRange):
+		status = http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		// Клиент отключился, ответ уже некому отправлять.
+		log.Logger.WithError(err).Info("Request cancelled")
+		c.Abort()
+		return
+	}
+	log.Logger.WithError(err).Warn("Request failed")
+	c.JSON(status, gin.H{"error": err.Error()})
 }
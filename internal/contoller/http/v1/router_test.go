@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"eth_bal/configs"
+	"eth_bal/internal/usecase"
+	"eth_bal/pkg/jsonrpc"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubCheckBlock реализует только методы, нужные маршрутам /admin и /healthz.
+type stubCheckBlock struct {
+	usecase.CheckBlock
+}
+
+func (stubCheckBlock) Providers() []jsonrpc.ProviderHealth {
+	return []jsonrpc.ProviderHealth{{Name: "primary", Breaker: jsonrpc.BreakerClosed}}
+}
+
+func (stubCheckBlock) RetryBudget() jsonrpc.RetryBudgetStats {
+	return jsonrpc.RetryBudgetStats{}
+}
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		path   string
+		want   int
+	}{
+		{"providers without header", "secret", "", "/admin/providers", http.StatusUnauthorized},
+		{"providers with wrong token", "secret", "Bearer nope", "/admin/providers", http.StatusUnauthorized},
+		{"providers with token", "secret", "Bearer secret", "/admin/providers", http.StatusOK},
+		{"admin disabled without token", "", "", "/admin/providers", http.StatusNotFound},
+		{"healthz stays public", "secret", "", "/healthz", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := gin.New()
+			NewRouter(handler, &configs.Config{Admin: configs.Admin{Token: tt.token}}, stubCheckBlock{})
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s: status %d, want %d", tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"eth_bal/internal/util"
+	"strconv"
+)
+
+// BlockNumber — номер блока. Ключи кэша и хранилища строятся из него, а не из
+// строки, которую вернул провайдер, поэтому "0x0a" и "0xA" — один и тот же блок.
+type BlockNumber int64
+
+// ParseBlockNumber разбирает номер блока в виде 0x-строки (в любом регистре
+// и с ведущими нулями) или в десятичном виде.
+func ParseBlockNumber(str string) (BlockNumber, error) {
+	n, err := util.ParseBlockNumber(str)
+	return BlockNumber(n), err
+}
+
+// Hex возвращает номер в каноническом виде для параметров JSON-RPC.
+func (n BlockNumber) Hex() string {
+	return "0x" + strconv.FormatInt(int64(n), 16)
+}
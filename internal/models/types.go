@@ -6,6 +6,44 @@ import (
 )
 
 type TransactionData struct {
+	From              string `json:"from"`
+	To                string `json:"to"`
+	Value             string `json:"value"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	BaseFeePerGas     string `json:"baseFeePerGas"`
+	Miner             string `json:"miner"`
+	BlockNumber       string `json:"blockNumber"`
+	TransactionIndex  string `json:"transactionIndex"`
+	// Status — статус из квитанции; у отменённой транзакции (TxStatusFailed)
+	// списывается только комиссия.
+	Status string `json:"status,omitempty"`
+	// ContractAddress — адрес контракта, созданного транзакцией без получателя.
+	ContractAddress string `json:"contractAddress,omitempty"`
+	// Traced означает, что переводы получены из трассировки вызовов,
+	// и Transfers заменяют верхнеуровневый Value.
+	Traced    bool       `json:"traced"`
+	Transfers []Transfer `json:"transfers,omitempty"`
+}
+
+// Recipient возвращает получателя перевода Value, как Transaction.Recipient.
+func (tx TransactionData) Recipient() string {
+	if tx.To == "" {
+		return tx.ContractAddress
+	}
+	return tx.To
+}
+
+// WithdrawalData — вывод со стейкинга, зачисленный адресу без транзакции. Amount в wei.
+type WithdrawalData struct {
+	Index       string `json:"index"`
+	Address     string `json:"address"`
+	Amount      string `json:"amount"`
+	BlockNumber string `json:"blockNumber"`
+}
+
+// Transfer — перевод ETH между адресами, в том числе внутренний (из вызова контракта).
+type Transfer struct {
 	From  string `json:"from"`
 	To    string `json:"to"`
 	Value string `json:"value"`
@@ -31,9 +69,25 @@ type RPCError struct {
 }
 
 type Block struct {
-	Number       string        `json:"number"`
-	Hash         string        `json:"hash"`
-	Transactions []Transaction `json:"transactions"`
+	Number        string        `json:"number"`
+	Hash          string        `json:"hash"`
+	ParentHash    string        `json:"parentHash"`
+	Miner         string        `json:"miner"`
+	BaseFeePerGas string        `json:"baseFeePerGas,omitempty"`
+	GasUsed       string        `json:"gasUsed"`
+	Transactions  []Transaction `json:"transactions"`
+	Withdrawals   []Withdrawal  `json:"withdrawals,omitempty"`
+	Traced        bool          `json:"-"`
+	// Confirmed — блок глубже confirmation_depth от головы и считается окончательным.
+	Confirmed bool `json:"-"`
+}
+
+// Withdrawal — вывод с Beacon Chain (EIP-4895). Amount указан в gwei.
+type Withdrawal struct {
+	Index          string `json:"index"`
+	ValidatorIndex string `json:"validatorIndex"`
+	Address        string `json:"address"`
+	Amount         string `json:"amount"`
 }
 
 type Transaction struct {
@@ -45,10 +99,199 @@ type Transaction struct {
 	GasPrice         string `json:"gasPrice"`
 	BlockNumber      string `json:"blockNumber"`
 	TransactionIndex string `json:"transactionIndex"`
+	// Поля ниже заполняются из квитанции транзакции.
+	GasUsed           string `json:"gasUsed,omitempty"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	Status            string `json:"status,omitempty"`
+	ContractAddress   string `json:"contractAddress,omitempty"`
+	// Заполняется из трассировки вызовов в режиме accounting_mode: trace.
+	InternalTransfers []Transfer `json:"internalTransfers,omitempty"`
+}
+
+// Recipient возвращает получателя перевода Value: адрес To или, если транзакция
+// создаёт контракт, адрес созданного контракта из квитанции.
+func (tx Transaction) Recipient() string {
+	if tx.To == "" {
+		return tx.ContractAddress
+	}
+	return tx.To
+}
+
+// Статусы транзакции в квитанции (EIP-658). У квитанций до Byzantium статуса нет.
+const (
+	TxStatusSuccess = "0x1"
+	TxStatusFailed  = "0x0"
+)
+
+type Receipt struct {
+	TransactionHash   string `json:"transactionHash"`
+	TransactionIndex  string `json:"transactionIndex"`
+	BlockNumber       string `json:"blockNumber"`
+	From              string `json:"from"`
+	To                string `json:"to"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	Status            string `json:"status"`
+	ContractAddress   string `json:"contractAddress"`
+}
+
+// CallFrame — кадр callTracer из debug_traceBlockByNumber.
+type CallFrame struct {
+	Type  string      `json:"type"`
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Value string      `json:"value"`
+	Error string      `json:"error,omitempty"`
+	Calls []CallFrame `json:"calls,omitempty"`
+}
+
+type TxTraceResult struct {
+	TxHash string     `json:"txHash"`
+	Result *CallFrame `json:"result"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// ParityTrace — элемент ответа trace_block.
+type ParityTrace struct {
+	Type   string `json:"type"`
+	Action struct {
+		CallType      string `json:"callType"`
+		From          string `json:"from"`
+		To            string `json:"to"`
+		Value         string `json:"value"`
+		Address       string `json:"address"`
+		RefundAddress string `json:"refundAddress"`
+		Balance       string `json:"balance"`
+	} `json:"action"`
+	Result *struct {
+		Address string `json:"address"`
+	} `json:"result"`
+	TraceAddress    []int  `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+	Error           string `json:"error,omitempty"`
+}
+
+// Log — запись журнала событий из eth_getLogs.
+type Log struct {
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+	Removed         bool     `json:"removed"`
+}
+
+// TokenInfo — метаданные токена. DecimalsKnown ложно, если decimals() не получен.
+type TokenInfo struct {
+	Address       string `json:"address"`
+	Symbol        string `json:"symbol"`
+	Decimals      int    `json:"decimals"`
+	DecimalsKnown bool   `json:"decimalsKnown"`
+}
+
+// TokenResultBlock — изменение баланса токена. ChangeRaw — в минимальных единицах
+// токена. Если decimals токена неизвестны, DecimalsUnknown = true и Change тоже
+// указан в минимальных единицах.
+type TokenResultBlock struct {
+	Token           string     `json:"token"`
+	Symbol          string     `json:"symbol"`
+	Decimals        int        `json:"decimals"`
+	DecimalsUnknown bool       `json:"decimalsUnknown,omitempty"`
+	Address         string     `json:"address"`
+	Change          *big.Float `json:"change"`
+	ChangeRaw       string     `json:"changeRaw"`
+	Sign            string     `json:"sign"`
+	From            int64      `json:"fromBlock"`
+	To              int64      `json:"toBlock"`
+}
+
+// Метки блоков, допустимые в параметрах запроса.
+const (
+	BlockTagLatest    = "latest"
+	BlockTagSafe      = "safe"
+	BlockTagFinalized = "finalized"
+)
+
+// CheckParams задаёт окно анализа для одного запроса. To — номер блока или метка
+// (latest, safe, finalized), From — номер или метка начала окна. Если From пуст,
+// окно отсчитывается от To на Blocks блоков назад.
+type CheckParams struct {
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Blocks int64  `json:"blocks,omitempty"`
+}
+
+// Направления ранжирования изменений баланса.
+const (
+	DirectionIncrease = "increase"
+	DirectionDecrease = "decrease"
+	DirectionAbs      = "abs"
+)
+
+// AddressChange — строка рейтинга адресов по изменению баланса.
+// WithdrawalsEth — часть изменения, пришедшая из выводов со стейкинга.
+type AddressChange struct {
+	Address        string     `json:"address"`
+	ChangeWei      string     `json:"changeWei"`
+	ChangeEth      *big.Float `json:"changeEth"`
+	TxCount        int        `json:"txCount"`
+	WithdrawalsEth *big.Float `json:"withdrawalsEth"`
+	FirstBlock     int64      `json:"firstBlock"`
+	LastBlock      int64      `json:"lastBlock"`
+}
+
+// Направления перевода относительно запрошенного адреса.
+const (
+	TransferIn   = "in"
+	TransferOut  = "out"
+	TransferSelf = "self"
+)
+
+// AddressTransfer — перевод или комиссия, затронувшие баланс адреса.
+type AddressTransfer struct {
+	BlockNumber     int64      `json:"blockNumber"`
+	TxHash          string     `json:"txHash"`
+	Direction       string     `json:"direction"`
+	Counterparty    string     `json:"counterparty"`
+	ValueWei        string     `json:"valueWei"`
+	ValueEth        *big.Float `json:"valueEth"`
+	FeeWei          string     `json:"feeWei"`
+	FeeEth          *big.Float `json:"feeEth"`
+	RunningTotalWei string     `json:"runningTotalWei"`
+	RunningTotalEth *big.Float `json:"runningTotalEth"`
+	// Для выводов со стейкинга TxHash пуст, а индекс вывода указан здесь.
+	WithdrawalIndex string `json:"withdrawalIndex,omitempty"`
+	// PriorityFee — чаевые за транзакцию TxHash, полученные адресом как
+	// получателем комиссии блока; Counterparty — отправитель транзакции.
+	PriorityFee bool `json:"priorityFee,omitempty"`
+}
+
+// AddressChanges — история изменений баланса адреса в окне блоков.
+type AddressChanges struct {
+	Address   string            `json:"address"`
+	From      int64             `json:"fromBlock"`
+	To        int64             `json:"toBlock"`
+	Transfers []AddressTransfer `json:"transfers"`
+	NetWei    string            `json:"netWei"`
+	NetEth    *big.Float        `json:"netEth"`
 }
 
 type ResultBlock struct {
-	Address   string     `json:"address"`
-	ChangeEth *big.Float `json:"changeEth"`
-	Sign      string     `json:"sign"`
+	Address     string               `json:"address"`
+	ChangeEth   *big.Float           `json:"changeEth"`
+	Sign        string               `json:"sign"`
+	BurnedEth   *big.Float           `json:"burnedEth"`
+	From        int64                `json:"fromBlock"`
+	To          int64                `json:"toBlock"`
+	HeadHash    string               `json:"headHash"`
+	Withdrawals WithdrawalsBreakdown `json:"withdrawals"`
+}
+
+// WithdrawalsBreakdown — выводы со стейкинга в окне анализа: общее число и сумма,
+// а также сумма, зачисленная найденному адресу.
+type WithdrawalsBreakdown struct {
+	Count      int        `json:"count"`
+	TotalEth   *big.Float `json:"totalEth"`
+	AddressEth *big.Float `json:"addressEth"`
 }
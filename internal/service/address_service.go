@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AddressChanges возвращает все переводы, затронувшие адрес в окне блоков, с нарастающим итогом.
+// После загрузки окна транзакции адреса берутся из индекса адресов BlockCache;
+// переводы считаются так же, как в ledger, поэтому NetWei совпадает с изменением
+// баланса адреса в /v1/top. Если окно не поместилось в память кэша, индекс неполон
+// и история строится перебором транзакций окна.
+func AddressChanges(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, sizer *BatchSizer, params models.CheckParams, address string) (models.AddressChanges, error) {
+	address = strings.ToLower(address)
+	_, window, err := loadWindow(ctx, cfg, client, sizer, params, false)
+	if err != nil {
+		return models.AddressChanges{}, err
+	}
+
+	blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+	var result models.AddressChanges
+	if activity, ok := blockCache.AddressActivity(address, models.BlockNumber(window.from), models.BlockNumber(window.to)); ok {
+		txs, withdrawals := indexedActivity(activity)
+		result = addressHistory(address, txs, withdrawals)
+	} else {
+		log.Logger.WithFields(logrus.Fields{
+			"from": window.from,
+			"to":   window.to,
+		}).Warn("Окно не помещается в кэш, история адреса строится перебором транзакций")
+		transactionsSet, collected, err := collectTransactions(ctx, cfg, client, sizer, params)
+		if err != nil {
+			return models.AddressChanges{}, err
+		}
+		window = collected
+		txs, withdrawals := setActivity(transactionsSet)
+		result = addressHistory(address, txs, withdrawals)
+	}
+	result.From, result.To = window.from, window.to
+	log.Logger.WithFields(logrus.Fields{
+		"address":   address,
+		"transfers": len(result.Transfers),
+		"net":       result.NetWei,
+	}).Info("История изменений баланса адреса построена")
+	return result, nil
+}
+
+// indexedActivity переводит записи индекса адресов в транзакции (по хэшу) и выводы
+// со стейкинга. Для чаевых берутся все транзакции блоков, добытых адресом.
+func indexedActivity(activity cache.AddressActivity) (map[string]models.TransactionData, []models.WithdrawalData) {
+	txs := make(map[string]models.TransactionData, len(activity.Transactions))
+	for _, indexed := range activity.Transactions {
+		txs[indexed.Tx.Hash] = newTransactionData(indexed.Block, indexed.Tx)
+	}
+	for _, block := range activity.Mined {
+		for _, tx := range block.Transactions {
+			txs[tx.Hash] = newTransactionData(block, tx)
+		}
+	}
+	withdrawals := make([]models.WithdrawalData, len(activity.Withdrawals))
+	for i, indexed := range activity.Withdrawals {
+		withdrawals[i] = newWithdrawalData(indexed.Block, indexed.Withdrawal)
+	}
+	return txs, withdrawals
+}
+
+// setActivity раскладывает набор транзакций окна на транзакции и выводы со стейкинга.
+func setActivity(transactionsSet *sync.Map) (map[string]models.TransactionData, []models.WithdrawalData) {
+	txs := make(map[string]models.TransactionData)
+	var withdrawals []models.WithdrawalData
+	transactionsSet.Range(func(key, value any) bool {
+		switch data := value.(type) {
+		case models.TransactionData:
+			txs[key.(string)] = data
+		case models.WithdrawalData:
+			withdrawals = append(withdrawals, data)
+		}
+		return true
+	})
+	return txs, withdrawals
+}
+
+// addressHistory выбирает из транзакций и выводов со стейкинга переводы адреса
+// и считает нарастающий итог.
+func addressHistory(address string, txs map[string]models.TransactionData, withdrawals []models.WithdrawalData) models.AddressChanges {
+	var events []addressEvent
+	for hash, tx := range txs {
+		if transfers := addressTransfers(address, hash, tx); len(transfers) > 0 {
+			events = append(events, addressEvent{
+				blockNumber: hexOrZero(tx.BlockNumber).Int64(),
+				position:    hexOrZero(tx.TransactionIndex).Int64(),
+				transfers:   transfers,
+			})
+		}
+	}
+	for _, w := range withdrawals {
+		if strings.EqualFold(w.Address, address) {
+			// Выводы обрабатываются после всех транзакций блока.
+			events = append(events, addressEvent{
+				blockNumber: hexOrZero(w.BlockNumber).Int64(),
+				position:    math.MaxInt32 + hexOrZero(w.Index).Int64(),
+				transfers:   []addressTransfer{withdrawalTransfer(w)},
+			})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].blockNumber != events[j].blockNumber {
+			return events[i].blockNumber < events[j].blockNumber
+		}
+		return events[i].position < events[j].position
+	})
+
+	result := models.AddressChanges{
+		Address:   address,
+		Transfers: []models.AddressTransfer{},
+	}
+	total := new(big.Int)
+	for _, event := range events {
+		for _, transfer := range event.transfers {
+			total.Add(total, transfer.net)
+			transfer.RunningTotalWei = total.String()
+			transfer.RunningTotalEth = util.WeiToEth(total)
+			result.Transfers = append(result.Transfers, transfer.AddressTransfer)
+		}
+	}
+	result.NetWei = total.String()
+	result.NetEth = util.WeiToEth(total)
+	return result
+}
+
+type addressTransfer struct {
+	models.AddressTransfer
+	net *big.Int
+}
+
+// addressEvent — транзакция или вывод со стейкинга; position задаёт порядок внутри блока.
+type addressEvent struct {
+	blockNumber int64
+	position    int64
+	transfers   []addressTransfer
+}
+
+// withdrawalTransfer представляет вывод со стейкинга как входящий перевод без контрагента.
+func withdrawalTransfer(w models.WithdrawalData) addressTransfer {
+	amount := hexOrZero(w.Amount)
+	return addressTransfer{
+		AddressTransfer: models.AddressTransfer{
+			BlockNumber:     hexOrZero(w.BlockNumber).Int64(),
+			Direction:       models.TransferIn,
+			ValueWei:        amount.String(),
+			ValueEth:        util.WeiToEth(amount),
+			FeeWei:          "0",
+			FeeEth:          new(big.Float),
+			WithdrawalIndex: w.Index,
+		},
+		net: amount,
+	}
+}
+
+// addressTransfers раскладывает транзакцию hash на переводы, затрагивающие адрес,
+// так же, как ledger.applyTransaction. Комиссия относится к первому исходящему
+// переводу отправителя; у отменённой транзакции остаётся только комиссия.
+// Чаевые получателю комиссии блока идут отдельным входящим переводом.
+func addressTransfers(address, hash string, tx models.TransactionData) []addressTransfer {
+	var transfers []models.Transfer
+	switch {
+	case tx.Status == models.TxStatusFailed:
+	case tx.Traced:
+		transfers = tx.Transfers
+	default:
+		transfers = []models.Transfer{{From: tx.From, To: tx.Recipient(), Value: tx.Value}}
+	}
+
+	fee, _, tip := txFees(tx)
+	if !strings.EqualFold(tx.From, address) {
+		fee.SetInt64(0)
+	}
+
+	var result []addressTransfer
+	for _, transfer := range transfers {
+		from, to := strings.ToLower(transfer.From), strings.ToLower(transfer.To)
+		if from != address && to != address {
+			continue
+		}
+		value := hexOrZero(transfer.Value)
+		entry := addressTransfer{net: new(big.Int)}
+		entry.ValueWei = value.String()
+		entry.ValueEth = util.WeiToEth(value)
+		switch {
+		case from == address && to == address:
+			entry.Direction = models.TransferSelf
+			entry.Counterparty = address
+		case from == address:
+			entry.Direction = models.TransferOut
+			entry.Counterparty = to
+			entry.net.Neg(value)
+		default:
+			entry.Direction = models.TransferIn
+			entry.Counterparty = from
+			entry.net.Set(value)
+		}
+		result = append(result, entry)
+	}
+
+	feeIndex := -1
+	if fee.Sign() > 0 {
+		for i := range result {
+			if result[i].Direction != models.TransferIn {
+				feeIndex = i
+				break
+			}
+		}
+		if feeIndex < 0 {
+			// Отправитель без исходящих переводов (вызов без ETH или откат) всё равно платит комиссию.
+			result = append([]addressTransfer{{
+				AddressTransfer: models.AddressTransfer{
+					Direction:    models.TransferOut,
+					Counterparty: strings.ToLower(tx.Recipient()),
+					ValueWei:     "0",
+					ValueEth:     new(big.Float),
+				},
+				net: new(big.Int),
+			}}, result...)
+			feeIndex = 0
+		}
+	}
+	if tip.Sign() > 0 && strings.EqualFold(tx.Miner, address) {
+		result = append(result, addressTransfer{
+			AddressTransfer: models.AddressTransfer{
+				Direction:    models.TransferIn,
+				Counterparty: strings.ToLower(tx.From),
+				ValueWei:     tip.String(),
+				ValueEth:     util.WeiToEth(tip),
+				PriorityFee:  true,
+			},
+			net: tip,
+		})
+	}
+
+	for i := range result {
+		result[i].BlockNumber = hexOrZero(tx.BlockNumber).Int64()
+		result[i].TxHash = hash
+		result[i].FeeWei = "0"
+		result[i].FeeEth = new(big.Float)
+		if i == feeIndex {
+			result[i].FeeWei = fee.String()
+			result[i].FeeEth = util.WeiToEth(fee)
+			result[i].net.Sub(result[i].net, fee)
+		}
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"testing"
+)
+
+// addressTestBlocks — два блока с обычным переводом, отменённой транзакцией,
+// созданием контракта, трассированными внутренними переводами, транзакцией
+// самого майнера и выводом.
+func addressTestBlocks() []*models.Block {
+	return []*models.Block{
+		{
+			Number: "0x1", Hash: "0xb1", ParentHash: "0xb0", Miner: _miner, BaseFeePerGas: "0x3",
+			Transactions: []models.Transaction{
+				{Hash: "0x01", From: _alice, To: _bob, Value: "0x64", GasUsed: "0xa", EffectiveGasPrice: "0x5",
+					BlockNumber: "0x1", TransactionIndex: "0x0"},
+				{Hash: "0x02", From: _bob, To: _carol, Value: "0x32", GasUsed: "0x2", EffectiveGasPrice: "0x4",
+					BlockNumber: "0x1", TransactionIndex: "0x1", Status: models.TxStatusFailed},
+				{Hash: "0x05", From: _bob, Value: "0xb", GasUsed: "0x3", EffectiveGasPrice: "0x4",
+					BlockNumber: "0x1", TransactionIndex: "0x2", ContractAddress: _carol},
+			},
+		},
+		{
+			Number: "0x2", Hash: "0xb2", ParentHash: "0xb1", Miner: _alice, BaseFeePerGas: "0x1", Traced: true,
+			Transactions: []models.Transaction{
+				{Hash: "0x03", From: _carol, To: _alice, Value: "0x0", GasUsed: "0x1", EffectiveGasPrice: "0x3",
+					BlockNumber: "0x2", TransactionIndex: "0x0", InternalTransfers: []models.Transfer{
+						{From: _carol, To: _alice, Value: "0x7"},
+						{From: _alice, To: _bob, Value: "0x2"},
+					}},
+				{Hash: "0x04", From: _miner, To: _miner, Value: "0x9", GasUsed: "0x1", EffectiveGasPrice: "0x2",
+					BlockNumber: "0x2", TransactionIndex: "0x1", InternalTransfers: []models.Transfer{
+						{From: _miner, To: _miner, Value: "0x9"},
+					}},
+			},
+			Withdrawals: []models.Withdrawal{{Index: "0x5", Address: _bob, Amount: "0x3"}},
+		},
+	}
+}
+
+// TestAddressHistoryMatchesLedger проверяет, что история адреса из индекса кэша
+// совпадает с изменением его баланса в ledger по тем же блокам окна.
+func TestAddressHistoryMatchesLedger(t *testing.T) {
+	blockCache, err := cache.NewBlockCache(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range addressTestBlocks() {
+		blockCache.Add(block)
+	}
+	window := blockWindow{from: 1, to: 2}
+	l, _ := buildLedger(loadCache(blockCache, window))
+
+	history := func(address string) models.AddressChanges {
+		t.Helper()
+		activity, ok := blockCache.AddressActivity(address, 1, 2)
+		if !ok {
+			t.Fatalf("%s: window is not covered by the address index", address)
+		}
+		txs, withdrawals := indexedActivity(activity)
+		return addressHistory(address, txs, withdrawals)
+	}
+	for _, address := range []string{_alice, _bob, _carol, _miner} {
+		got := history(address)
+		want := "0"
+		if e, ok := l.entries[address]; ok {
+			want = e.delta.String()
+		}
+		if got.NetWei != want {
+			t.Errorf("%s: history net %s, ledger delta %s", address, got.NetWei, want)
+		}
+		if n := len(got.Transfers); n > 0 && got.Transfers[n-1].RunningTotalWei != got.NetWei {
+			t.Errorf("%s: last running total %s, net %s", address, got.Transfers[n-1].RunningTotalWei, got.NetWei)
+		}
+	}
+
+	// У отменённой транзакции 0x02 боб платит только комиссию, а кэрол ничего не получает.
+	for _, transfer := range history(_carol).Transfers {
+		if transfer.TxHash == "0x02" {
+			t.Errorf("carol: unexpected transfer from reverted tx: %+v", transfer)
+		}
+	}
+	// Значение транзакции без получателя зачисляется созданному контракту.
+	created := false
+	for _, transfer := range history(_carol).Transfers {
+		created = created || (transfer.TxHash == "0x05" && transfer.Direction == models.TransferIn && transfer.ValueWei == "11")
+	}
+	if !created {
+		t.Error("carol: contract creation value is not credited to the created contract")
+	}
+	// Чаевые майнер получает и за транзакции, в которых не участвует.
+	tips := 0
+	for _, transfer := range history(_miner).Transfers {
+		if transfer.PriorityFee {
+			tips++
+		}
+	}
+	if tips != 3 {
+		t.Errorf("miner: %d priority fee transfers, want 3", tips)
+	}
+
+	// Перебор набора транзакций окна даёт ту же историю.
+	txs, withdrawals := setActivity(loadCache(blockCache, window))
+	for _, address := range []string{_alice, _bob, _carol, _miner} {
+		if got, want := addressHistory(address, txs, withdrawals).NetWei, history(address).NetWei; got != want {
+			t.Errorf("%s: scanned history net %s, indexed %s", address, got, want)
+		}
+	}
+}
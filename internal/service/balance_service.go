@@ -1,167 +1,329 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"eth_bal/configs"
 	"eth_bal/internal/cache"
 	"eth_bal/internal/models"
 	"eth_bal/internal/usecase/webapi"
 	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
 	"eth_bal/pkg/log"
 	"fmt"
 	"math/big"
-	"net/http"
-	"os"
 	"runtime"
+	"slices"
 	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
-func EthChecker(cfg *configs.Config) models.ResultBlock {
-	runtime.GOMAXPROCS(runtime.NumCPU())
-	apiKey := getAPIKey(cfg)
-	blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
-	transactionsSet := loadCache(blockCache)
-	client := createHTTPClient(cfg)
-	latestBlockNumber := getLatestBlockNumber(client, apiKey)
-	startBlockNumber := calculateStartBlockNumber(latestBlockNumber, cfg)
-	analyzeBlocks(client, apiKey, blockCache, transactionsSet, latestBlockNumber, startBlockNumber, cfg)
-	maxAddress, maxChange, sign := findMaxChangeAddress(transactionsSet)
+// _maxReorgPasses ограничивает число повторных проходов по окну после реорганизации.
+const _maxReorgPasses = 3
+
+// IncompleteWindowError возвращается, если часть блоков окна не удалось загрузить
+// (вместе с квитанциями и трассировкой): результат по окну с пропусками не отдаётся.
+// Missing — номера незагруженных блоков по возрастанию, Err — одна из ошибок загрузки.
+type IncompleteWindowError struct {
+	From, To int64
+	Missing  []int64
+	Err      error
+}
+
+func (e *IncompleteWindowError) Error() string {
+	return fmt.Sprintf("window %d..%d is incomplete: %d blocks not loaded: %v", e.From, e.To, len(e.Missing), e.Err)
+}
+
+func (e *IncompleteWindowError) Unwrap() error { return e.Err }
+
+// newIncompleteWindowError собирает ошибку по незагруженным блокам окна; nil, если пропусков нет.
+func newIncompleteWindowError(window blockWindow, missing map[models.BlockNumber]error) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	e := &IncompleteWindowError{From: window.from, To: window.to, Missing: make([]int64, 0, len(missing))}
+	for number, err := range missing {
+		e.Missing = append(e.Missing, int64(number))
+		e.Err = err
+	}
+	slices.Sort(e.Missing)
+	return e
+}
+
+func EthChecker(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, sizer *BatchSizer, params models.CheckParams) (models.ResultBlock, error) {
+	transactionsSet, window, err := collectTransactions(ctx, cfg, client, sizer, params)
+	if err != nil {
+		return models.ResultBlock{}, err
+	}
+	l, count := buildLedger(transactionsSet)
+	maxAddress, maxChange, sign := findMaxChangeAddress(l, count)
 	logMaxChangeAddress(maxAddress, maxChange)
+	addressWithdrawals := new(big.Int)
+	if e, ok := l.entries[maxAddress]; ok {
+		addressWithdrawals = e.withdrawals
+	}
 	return models.ResultBlock{
 		Address:   maxAddress,
 		ChangeEth: util.WeiToEth(maxChange),
 		Sign:      sign,
+		BurnedEth: util.WeiToEth(l.burned),
+		From:      window.from,
+		To:        window.to,
+		HeadHash:  window.toHash,
+		Withdrawals: models.WithdrawalsBreakdown{
+			Count:      l.withdrawalCount,
+			TotalEth:   util.WeiToEth(l.withdrawals),
+			AddressEth: util.WeiToEth(addressWithdrawals),
+		},
+	}, nil
+}
+
+// TopChanges возвращает до n адресов с наибольшими чистыми изменениями баланса
+// по тому же набору блоков, что и EthChecker.
+func TopChanges(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, sizer *BatchSizer, params models.CheckParams, n int, direction string) ([]models.AddressChange, error) {
+	transactionsSet, _, err := collectTransactions(ctx, cfg, client, sizer, params)
+	if err != nil {
+		return nil, err
 	}
+	l, _ := buildLedger(transactionsSet)
+	top := l.top(n, direction)
+	result := make([]models.AddressChange, len(top))
+	for i, e := range top {
+		result[i] = models.AddressChange{
+			Address:        e.address,
+			ChangeWei:      e.delta.String(),
+			ChangeEth:      util.WeiToEth(e.delta),
+			TxCount:        e.txCount,
+			WithdrawalsEth: util.WeiToEth(e.withdrawals),
+			FirstBlock:     e.firstBlock,
+			LastBlock:      e.lastBlock,
+		}
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"n":         n,
+		"direction": direction,
+		"returned":  len(result),
+	}).Info("Рейтинг изменений баланса построен")
+	return result, nil
 }
 
-func getAPIKey(cfg *configs.Config) string {
-	apiKey := cfg.GETBLOCK_API_KEY
-	if apiKey == "" {
-		apiKey = os.Getenv("GETBLOCK_API_KEY")
+func collectTransactions(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, sizer *BatchSizer, params models.CheckParams) (*sync.Map, blockWindow, error) {
+	return loadWindow(ctx, cfg, client, sizer, params, true)
+}
+
+// loadWindow определяет окно params и загружает в кэш его недостающие блоки,
+// повторяя проход после реорганизации. С collect возвращает набор транзакций
+// и выводов со стейкинга всего окна, без него — только загруженных блоков.
+func loadWindow(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, sizer *BatchSizer, params models.CheckParams, collect bool) (*sync.Map, blockWindow, error) {
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	window, err := resolveWindow(ctx, client, cfg, params)
+	if err != nil {
+		return nil, window, err
 	}
-	if apiKey == "" {
-		log.Logger.Fatal("Переменная окружения GETBLOCK_API_KEY не установлена")
+	blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+	for pass := 1; ; pass++ {
+		transactionsSet := &sync.Map{}
+		if collect {
+			transactionsSet = loadCache(blockCache, window)
+		}
+		orphaned, missing := analyzeBlocks(ctx, client, sizer, blockCache, transactionsSet, window, cfg)
+		if err := ctx.Err(); err != nil {
+			// Часть пакетов не загружена, неполный результат не возвращается.
+			return nil, window, err
+		}
+		if err := newIncompleteWindowError(window, missing); err != nil {
+			return nil, window, err
+		}
+		if len(orphaned) == 0 || pass == _maxReorgPasses {
+			if block, found := blockCache.GetByNumber(models.BlockNumber(window.to)); found {
+				window.toHash = block.Hash
+			}
+			return transactionsSet, window, nil
+		}
+		// Транзакции осиротевших блоков уже попали в набор, поэтому окно собирается заново.
+		log.Logger.WithFields(logrus.Fields{
+			"orphaned": len(orphaned),
+			"pass":     pass,
+		}).Warn("Обнаружена реорганизация цепочки, окно анализируется повторно")
 	}
-	return apiKey
 }
 
-func loadCache(blockCache *cache.BlockCache) *sync.Map {
+// loadCache собирает транзакции окна из кэша; промахи дочитываются из постоянного хранилища.
+func loadCache(blockCache *cache.BlockCache, window blockWindow) *sync.Map {
 	transactionsSet := &sync.Map{}
 	log.Logger.WithField("cache_size", blockCache.Size()).Info("Кэш успешно загружен.")
-	for _, key := range blockCache.Keys() {
-		if block, found := blockCache.Get(key); found {
-			for _, tx := range block.Transactions {
-				transactionsSet.Store(tx.Hash, models.TransactionData{
-					From:  tx.From,
-					To:    tx.To,
-					Value: tx.Value,
-				})
-			}
-		}
+	for _, block := range blockCache.Range(models.BlockNumber(window.from), models.BlockNumber(window.to)) {
+		storeBlock(transactionsSet, block)
 	}
 	return transactionsSet
 }
 
-func createHTTPClient(cfg *configs.Config) *http.Client {
-	return &http.Client{
-		Timeout: cfg.HTTPClientTimeout,
-		Transport: &http.Transport{
-			MaxIdleConns:        cfg.MaxIdleConns,
-			MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
-			IdleConnTimeout:     cfg.IdleConnTimeout,
-		},
+// storeBlock добавляет в набор транзакции блока (по хэшу) и выводы со стейкинга (по индексу вывода).
+func storeBlock(transactionsSet *sync.Map, block *models.Block) {
+	for _, tx := range block.Transactions {
+		transactionsSet.Store(tx.Hash, newTransactionData(block, tx))
+	}
+	for _, w := range block.Withdrawals {
+		transactionsSet.Store(cache.WithdrawalKey(w.Index), newWithdrawalData(block, w))
 	}
 }
 
-func getLatestBlockNumber(client *http.Client, apiKey string) int64 {
-	latestBlockNumberHex, err := webapi.GetLatestBlockNumber(client, apiKey)
-	if err != nil {
-		log.Logger.Fatalf("Не удалось получить последний номер блока: %v", err)
+// newWithdrawalData переводит сумму вывода из gwei в wei.
+func newWithdrawalData(block *models.Block, w models.Withdrawal) models.WithdrawalData {
+	amount := new(big.Int).Mul(hexOrZero(w.Amount), big.NewInt(1e9))
+	return models.WithdrawalData{
+		Index:       w.Index,
+		Address:     w.Address,
+		Amount:      "0x" + amount.Text(16),
+		BlockNumber: block.Number,
+	}
+}
+
+func newTransactionData(block *models.Block, tx models.Transaction) models.TransactionData {
+	return models.TransactionData{
+		From:              tx.From,
+		To:                tx.To,
+		Value:             tx.Value,
+		GasUsed:           tx.GasUsed,
+		EffectiveGasPrice: tx.EffectiveGasPrice,
+		Status:            tx.Status,
+		ContractAddress:   tx.ContractAddress,
+		BaseFeePerGas:     block.BaseFeePerGas,
+		Miner:             block.Miner,
+		BlockNumber:       block.Number,
+		TransactionIndex:  tx.TransactionIndex,
+		Traced:            block.Traced,
+		Transfers:         tx.InternalTransfers,
 	}
-	return util.HexToInt(latestBlockNumberHex)
 }
 
-func calculateStartBlockNumber(latestBlockNumber int64, cfg *configs.Config) int64 {
-	startBlockNumber := latestBlockNumber - cfg.BlocksToAnalyze
-	if startBlockNumber < 0 {
-		log.Logger.Fatal("Неверное значение начального номера блока")
+func isTraceMode(cfg *configs.Config) bool {
+	return cfg.AccountingMode == configs.AccountingModeTrace
+}
+
+func getLatestBlockNumber(ctx context.Context, client *jsonrpc.Client) (int64, error) {
+	latestBlockNumberHex, err := webapi.GetLatestBlockNumber(ctx, client)
+	if err != nil {
+		log.Logger.Errorf("Не удалось получить последний номер блока: %v", err)
+		return 0, err
 	}
-	return startBlockNumber
+	return util.HexToInt(latestBlockNumberHex), nil
 }
 
-func analyzeBlocks(client *http.Client, apiKey string, blockCache *cache.BlockCache, transactionsSet *sync.Map, latestBlockNumber, startBlockNumber int64, cfg *configs.Config) {
+// analyzeBlocks загружает недостающие блоки окна и возвращает номера блоков,
+// удалённых из кэша из-за реорганизации, и блоков, которые не удалось загрузить,
+// с ошибкой по каждому. Блоки ближе confirmation_depth к голове
+// не считаются подтверждёнными и перезапрашиваются при каждой проверке.
+// При отмене ctx новые пакеты не запускаются, а уже запущенные прерываются на HTTP-запросе.
+func analyzeBlocks(ctx context.Context, client *jsonrpc.Client, sizer *BatchSizer, blockCache *cache.BlockCache, transactionsSet *sync.Map, window blockWindow, cfg *configs.Config) ([]models.BlockNumber, map[models.BlockNumber]error) {
+	var (
+		mu       sync.Mutex
+		orphaned []models.BlockNumber
+		missing  = make(map[models.BlockNumber]error)
+	)
+	fail := func(err error, numbers ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, hex := range numbers {
+			if number, parseErr := models.ParseBlockNumber(hex); parseErr == nil {
+				missing[number] = err
+			}
+		}
+	}
+	// Пока подписка newHeads жива, неподтверждённые блоки в кэше поддерживает
+	// IngestHeads, и перезапрашивать их не нужно.
+	_, _, live := blockCache.Head(cfg.WS.HeadMaxAge)
+	// Размер пакета подстраивается по ответам уже отправленных пакетов; пакет
+	// набирается из блоков, которые действительно нужно загрузить.
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, runtime.NumCPU()*2)
-	for i := latestBlockNumber; i > startBlockNumber; i -= cfg.BatchSize {
-		wg.Add(1)
-		sem <- struct{}{}
+	for i := window.to; i >= window.from; {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return orphaned, missing
+		case sem <- struct{}{}:
+		}
+		size := sizer.next()
 		var batchBlocks []string
-		for j := i; j > i-cfg.BatchSize && j >= startBlockNumber; j-- {
-			blockNumberHex := util.IntToHex(j)
-			if _, found := blockCache.Get(blockNumberHex); !found {
-				batchBlocks = append(batchBlocks, blockNumberHex)
+		for ; i >= window.from && int64(len(batchBlocks)) < size; i-- {
+			if block, found := blockCache.GetByNumber(models.BlockNumber(i)); !found || (!block.Confirmed && !live) || (isTraceMode(cfg) && !block.Traced) {
+				batchBlocks = append(batchBlocks, models.BlockNumber(i).Hex())
 			}
 		}
 		if len(batchBlocks) > 0 {
+			wg.Add(1)
 			go func(batchBlocks []string) {
 				defer wg.Done()
 				defer func() { <-sem }()
-				blocks, err := webapi.GetBlocksByNumbers(client, apiKey, batchBlocks, true)
-				if err != nil {
-					log.Logger.Warn("Не удалось загрузить блоки")
+				batch, err := webapi.GetBlocksByNumbers(ctx, client, batchBlocks, true)
+				sizer.observe(int64(len(batchBlocks)), batch, err)
+				var batchErr *webapi.BatchError
+				if err != nil && !errors.As(err, &batchErr) {
+					log.Logger.WithError(err).Warn("Не удалось загрузить блоки")
+					fail(err, batchBlocks...)
+					return
+				}
+				if batchErr != nil {
+					// Загруженные блоки используем, остальные попадут в следующий запрос.
+					log.Logger.WithError(err).WithField("failed", len(batch.Failed)).Warn("Часть блоков не загружена")
+					for number, err := range batch.Failed {
+						fail(err, number)
+					}
+				}
+				blocks := batch.Blocks
+				loaded := make([]string, len(blocks))
+				for i, block := range blocks {
+					loaded[i] = block.Number
+				}
+				if err := webapi.FillReceipts(ctx, client, blocks); err != nil {
+					log.Logger.WithError(err).Warn("Не удалось загрузить квитанции транзакций")
+					fail(err, loaded...)
 					return
 				}
+				if isTraceMode(cfg) {
+					if err := webapi.FillTraces(ctx, client, blocks, cfg.TraceMethod); err != nil {
+						log.Logger.WithError(err).Warn("Не удалось загрузить трассировку блоков")
+						fail(err, loaded...)
+						return
+					}
+				}
 				for _, block := range blocks {
-					blockCache.Add(block.Number, block)
-					for _, tx := range block.Transactions {
-						transactionsSet.Store(tx.Hash, models.TransactionData{
-							From:  tx.From,
-							To:    tx.To,
-							Value: tx.Value,
-						})
+					block.Confirmed = window.head-util.HexToInt(block.Number) >= cfg.ConfirmationDepth
+					if evicted := blockCache.Add(block); len(evicted) > 0 {
+						mu.Lock()
+						orphaned = append(orphaned, evicted...)
+						mu.Unlock()
 					}
+					storeBlock(transactionsSet, block)
 				}
 			}(batchBlocks)
 		} else {
-			wg.Done()
 			<-sem
 		}
 	}
 	wg.Wait()
+	return orphaned, missing
 }
 
-func findMaxChangeAddress(transactionsSet *sync.Map) (string, *big.Int, string) {
-	var allTransactions []models.TransactionData
-	transactionsSet.Range(func(key, value interface{}) bool {
-		transaction := value.(models.TransactionData)
-		allTransactions = append(allTransactions, transaction)
-		return true
-	})
-	fmt.Println("Всего транзакций:", len(allTransactions))
-	var maxAddress string
-	var sign string = "increase"
-	maxChange := big.NewInt(0)
-	for _, tx := range allTransactions {
-		change := util.HexToBigInt(util.TrimQuotes(tx.Value))
-		if change.Cmp(big.NewInt(0)) < 0 {
-			sign = "decrease"
-		}
-		absChange := new(big.Int).Abs(change)
-		if absChange.Cmp(maxChange) > 0 {
-			maxChange = absChange
-			maxAddress = tx.From
-		}
+func findMaxChangeAddress(l *ledger, count int) (string, *big.Int, string) {
+	log.Logger.WithFields(logrus.Fields{
+		"transactions": count,
+		"addresses":    len(l.entries),
+	}).Info("Изменения баланса посчитаны")
+	maxAddress, maxDelta := l.max()
+	sign := models.DirectionIncrease
+	if maxDelta.Sign() < 0 {
+		sign = models.DirectionDecrease
 	}
-	return maxAddress, maxChange, sign
+	return maxAddress, new(big.Int).Abs(maxDelta), sign
 }
 
 func logMaxChangeAddress(maxAddress string, maxChange *big.Int) {
 	log.Logger.WithFields(logrus.Fields{
 		"max_address": maxAddress,
 		"max_change":  maxChange.String(),
+		"max_eth":     util.WeiToEth(maxChange).String(),
 	}).Info("Адрес с максимальным изменением баланса найден")
-	fmt.Println("")
-	fmt.Printf("Адрес с максимальным изменением баланса: %s\n", maxAddress)
-	fmt.Printf("Изменение баланса: %s WEL, %s Eth\n", maxChange.String(), util.WeiToEth(maxChange).String())
 }
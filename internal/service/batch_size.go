@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/usecase/webapi"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// BatchSizer подбирает размер пакета eth_getBlockByNumber: уменьшает его вдвое
+// после ошибки, ужимает до целевых объёма ответа и задержки, если пакет их
+// превысил, и увеличивает на один блок, пока запас по обоим больше четверти.
+// Один BatchSizer разделяют все проверки, идущие через одного клиента JSON-RPC.
+type BatchSizer struct {
+	min           int64
+	max           int64
+	targetBytes   int64
+	targetLatency time.Duration
+
+	mu   sync.Mutex
+	size int64
+}
+
+// NewBatchSizer создаёт BatchSizer по параметрам batch_size* конфигурации.
+func NewBatchSizer(cfg *configs.Config) *BatchSizer {
+	s := &BatchSizer{
+		min:           max(cfg.BatchSizeMin, 1),
+		max:           max(cfg.BatchSizeMax, cfg.BatchSize),
+		targetBytes:   cfg.BatchTargetBytes,
+		targetLatency: cfg.BatchTargetLatency,
+	}
+	s.size = min(max(cfg.BatchSize, s.min), s.max)
+	return s
+}
+
+func (s *BatchSizer) next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// observe учитывает результат пакета из n блоков.
+func (s *BatchSizer) observe(n int64, batch webapi.BlockBatch, err error) {
+	if n == 0 || errors.Is(err, jsonrpc.ErrCircuitOpen) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// Недоступность провайдера и отмена проверки не связаны с размером пакета.
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size, reason := s.size, ""
+	switch {
+	case err != nil:
+		size, reason = s.size/2, "error"
+	case s.targetBytes > 0 && batch.Bytes > s.targetBytes:
+		size, reason = n*s.targetBytes/batch.Bytes, "bytes"
+	case s.targetLatency > 0 && batch.Latency > s.targetLatency:
+		size, reason = int64(float64(n)*float64(s.targetLatency)/float64(batch.Latency)), "latency"
+	case n >= s.size && (s.targetBytes == 0 || batch.Bytes < s.targetBytes*3/4) &&
+		(s.targetLatency == 0 || batch.Latency < s.targetLatency*3/4):
+		// Растём только по полноразмерным пакетам: короткий хвост окна ничего не говорит о запасе.
+		size, reason = s.size+1, "headroom"
+	}
+	size = min(max(size, s.min), s.max)
+	if size == s.size {
+		return
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"from":    s.size,
+		"to":      size,
+		"reason":  reason,
+		"blocks":  n,
+		"bytes":   batch.Bytes,
+		"latency": batch.Latency,
+		"min":     s.min,
+		"max":     s.max,
+		"error":   err,
+	}).Info("Batch size adjusted")
+	s.size = size
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/usecase/webapi"
+	"eth_bal/pkg/jsonrpc"
+	"testing"
+	"time"
+)
+
+func TestNewBatchSizer(t *testing.T) {
+	tests := []struct {
+		name               string
+		size, minSz, maxSz int64
+		want               int64
+		wantMin, wantMax   int64
+	}{
+		{"start size inside bounds", 20, 5, 50, 20, 5, 50},
+		{"start size below min", 2, 5, 50, 5, 5, 50},
+		{"max below start size", 20, 5, 10, 20, 5, 20},
+		{"zero min", 20, 0, 50, 20, 1, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewBatchSizer(&configs.Config{BatchSize: tt.size, BatchSizeMin: tt.minSz, BatchSizeMax: tt.maxSz})
+			if got := s.next(); got != tt.want || s.min != tt.wantMin || s.max != tt.wantMax {
+				t.Errorf("size %d in [%d, %d], want %d in [%d, %d]", got, s.min, s.max, tt.want, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestBatchSizerObserve(t *testing.T) {
+	const (
+		targetBytes   = 1000
+		targetLatency = time.Second
+	)
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name  string
+		size  int64 // текущий размер перед observe
+		n     int64 // блоков в пакете
+		batch webapi.BlockBatch
+		err   error
+		want  int64
+	}{
+		{"error halves", 20, 20, webapi.BlockBatch{}, errFailed, 10},
+		{"error halving stops at min", 6, 6, webapi.BlockBatch{}, errFailed, 4},
+		{"bytes over target shrink proportionally", 20, 20, webapi.BlockBatch{Bytes: 4000, Latency: 100 * time.Millisecond}, nil, 5},
+		{"latency over target shrinks proportionally", 20, 20, webapi.BlockBatch{Bytes: 100, Latency: 2 * time.Second}, nil, 10},
+		{"bytes checked before latency", 20, 20, webapi.BlockBatch{Bytes: 2000, Latency: 4 * time.Second}, nil, 10},
+		{"headroom grows by one", 20, 20, webapi.BlockBatch{Bytes: 500, Latency: 500 * time.Millisecond}, nil, 21},
+		{"growth stops at max", 30, 30, webapi.BlockBatch{Bytes: 100, Latency: time.Millisecond}, nil, 30},
+		{"no headroom keeps size", 20, 20, webapi.BlockBatch{Bytes: 900, Latency: 100 * time.Millisecond}, nil, 20},
+		{"short tail batch does not grow", 20, 3, webapi.BlockBatch{Bytes: 100, Latency: time.Millisecond}, nil, 20},
+		{"shrink stops at min", 20, 20, webapi.BlockBatch{Bytes: 100_000}, nil, 4},
+		{"circuit open ignored", 20, 20, webapi.BlockBatch{}, jsonrpc.ErrCircuitOpen, 20},
+		{"cancellation ignored", 20, 20, webapi.BlockBatch{}, context.Canceled, 20},
+		{"empty batch ignored", 20, 0, webapi.BlockBatch{}, errFailed, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewBatchSizer(&configs.Config{
+				BatchSize:          tt.size,
+				BatchSizeMin:       4,
+				BatchSizeMax:       30,
+				BatchTargetBytes:   targetBytes,
+				BatchTargetLatency: targetLatency,
+			})
+			s.observe(tt.n, tt.batch, tt.err)
+			if got := s.next(); got != tt.want {
+				t.Errorf("size %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBatchSizerNoTargets: без целевых объёма и задержки размер растёт до max
+// и уменьшается только по ошибкам.
+func TestBatchSizerNoTargets(t *testing.T) {
+	s := NewBatchSizer(&configs.Config{BatchSize: 8, BatchSizeMin: 1, BatchSizeMax: 10})
+	for i := 0; i < 5; i++ {
+		s.observe(s.next(), webapi.BlockBatch{Bytes: 1 << 30, Latency: time.Hour}, nil)
+	}
+	if got := s.next(); got != 10 {
+		t.Fatalf("size %d after growth, want 10", got)
+	}
+	s.observe(10, webapi.BlockBatch{}, errors.New("failed"))
+	if got := s.next(); got != 5 {
+		t.Errorf("size %d after error, want 5", got)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrPrefetchRunning возвращается, если предыдущая предзагрузка ещё не закончилась.
+var ErrPrefetchRunning = errors.New("prefetch is already running")
+
+// PrefetchStatus — состояние фоновой предзагрузки блоков. Cached — сколько блоков
+// окна оказалось в памяти после её завершения, Missing — сколько не удалось загрузить.
+type PrefetchStatus struct {
+	Running  bool       `json:"running"`
+	From     int64      `json:"from"`
+	To       int64      `json:"to"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Cached   int        `json:"cached"`
+	Orphaned int        `json:"orphaned"`
+	Missing  int        `json:"missing"`
+}
+
+// CacheStatus — состояние кэша блоков и последней предзагрузки.
+type CacheStatus struct {
+	cache.Stats
+	Prefetch *PrefetchStatus `json:"prefetch,omitempty"`
+}
+
+var (
+	prefetchMu    sync.Mutex
+	prefetchState *PrefetchStatus
+)
+
+func CacheStats(cfg *configs.Config) CacheStatus {
+	status := CacheStatus{Stats: cache.GetGlobalBlockCache(cfg.CacheSize).Stats()}
+	prefetchMu.Lock()
+	defer prefetchMu.Unlock()
+	if prefetchState != nil {
+		prefetch := *prefetchState
+		status.Prefetch = &prefetch
+	}
+	return status
+}
+
+// PurgeCache очищает кэш блоков, а с withStore — и постоянное хранилище.
+func PurgeCache(cfg *configs.Config, withStore bool) int {
+	evicted := cache.GetGlobalBlockCache(cfg.CacheSize).Purge(withStore)
+	log.Logger.WithFields(logrus.Fields{
+		"evicted": evicted,
+		"store":   withStore,
+	}).Warn("Кэш блоков очищен")
+	return evicted
+}
+
+// EvictBlocks удаляет из кэша блоки с номерами от from до to, а с withStore —
+// и из постоянного хранилища.
+func EvictBlocks(cfg *configs.Config, from, to models.BlockNumber, withStore bool) int {
+	evicted := cache.GetGlobalBlockCache(cfg.CacheSize).EvictRange(from, to, withStore)
+	log.Logger.WithFields(logrus.Fields{
+		"from":    from,
+		"to":      to,
+		"evicted": evicted,
+		"store":   withStore,
+	}).Warn("Блоки удалены из кэша")
+	return evicted
+}
+
+// Prefetch проверяет окно params так же, как проверка баланса, и возвращает
+// задачу, которая загружает в кэш недостающие блоки окна. Задача не зависит от
+// ctx запроса: её запускает вызывающая сторона с контекстом времени жизни сервиса,
+// чтобы остановить до сохранения снимка. Одновременно идёт одна предзагрузка.
+func Prefetch(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, sizer *BatchSizer, params models.CheckParams) (PrefetchStatus, func(context.Context), error) {
+	if status, running := prefetchRunning(); running {
+		return status, nil, ErrPrefetchRunning
+	}
+	// Окно определяется по узлу, поэтому без блокировки: CacheStats не ждёт сети.
+	window, err := resolveWindow(ctx, client, cfg, params)
+	if err != nil {
+		return PrefetchStatus{}, nil, err
+	}
+	prefetchMu.Lock()
+	defer prefetchMu.Unlock()
+	if prefetchState != nil && prefetchState.Running {
+		return *prefetchState, nil, ErrPrefetchRunning
+	}
+	state := &PrefetchStatus{Running: true, From: window.from, To: window.to, Started: time.Now()}
+	prefetchState = state
+	job := func(ctx context.Context) {
+		blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+		orphaned, missing := analyzeBlocks(ctx, client, sizer, blockCache, &sync.Map{}, window, cfg)
+		cached := 0
+		for _, number := range blockCache.Keys() {
+			if window.contains(int64(number)) {
+				cached++
+			}
+		}
+		finished := time.Now()
+		prefetchMu.Lock()
+		state.Running, state.Finished, state.Cached, state.Orphaned, state.Missing = false, &finished, cached, len(orphaned), len(missing)
+		prefetchMu.Unlock()
+		logger := log.Logger.WithFields(logrus.Fields{
+			"from":     window.from,
+			"to":       window.to,
+			"cached":   cached,
+			"missing":  len(missing),
+			"duration": finished.Sub(state.Started),
+		})
+		if ctx.Err() != nil {
+			logger.Warn("Предзагрузка блоков прервана")
+			return
+		}
+		logger.Info("Предзагрузка блоков завершена")
+	}
+	return *state, job, nil
+}
+
+func prefetchRunning() (PrefetchStatus, bool) {
+	prefetchMu.Lock()
+	defer prefetchMu.Unlock()
+	if prefetchState != nil && prefetchState.Running {
+		return *prefetchState, true
+	}
+	return PrefetchStatus{}, false
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"eth_bal/internal/usecase/webapi"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IngestHeads держит кэш блоков в актуальном состоянии по подписке newHeads:
+// каждый новый блок (и пропущенные с прошлого заголовка) загружается целиком,
+// а блок на глубине cfg.ConfirmationDepth помечается подтверждённым.
+// Работает до отмены ctx.
+func IngestHeads(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, subscriber *jsonrpc.HeadSubscriber) {
+	blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+	var last int64 = -1
+	subscriber.Run(ctx, func(header models.Block) {
+		parsed, err := models.ParseBlockNumber(header.Number)
+		if err != nil {
+			log.Logger.WithError(err).WithField("header_number", header.Number).Warn("Пропущен заголовок с некорректным номером блока")
+			return
+		}
+		number := int64(parsed)
+		from := number
+		// После переподключения догружаем пропущенные блоки, но не глубже
+		// зоны подтверждения: старше неё блоки загрузит обычный анализ.
+		if last >= 0 && last < number {
+			from = max(last+1, number-cfg.ConfirmationDepth)
+		}
+		if err := ingestBlocks(ctx, cfg, client, blockCache, from, number); err != nil {
+			log.Logger.WithError(err).WithField("block_number", number).Warn("Не удалось загрузить новый блок")
+			return
+		}
+		last = number
+		blockCache.SetHead(models.BlockNumber(number), header.Hash)
+		confirmBlock(blockCache, number, cfg.ConfirmationDepth)
+	})
+}
+
+func ingestBlocks(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, blockCache *cache.BlockCache, from, to int64) error {
+	var numbers []string
+	for n := from; n <= to; n++ {
+		numbers = append(numbers, util.IntToHex(n))
+	}
+	batch, fetchErr := webapi.GetBlocksByNumbers(ctx, client, numbers, true)
+	var batchErr *webapi.BatchError
+	if fetchErr != nil && !errors.As(fetchErr, &batchErr) {
+		return fetchErr
+	}
+	if err := webapi.FillReceipts(ctx, client, batch.Blocks); err != nil {
+		return err
+	}
+	if isTraceMode(cfg) {
+		if err := webapi.FillTraces(ctx, client, batch.Blocks, cfg.TraceMethod); err != nil {
+			return err
+		}
+	}
+	// Загруженные блоки кладём в кэш даже при частичной неудаче, но голову
+	// не сдвигаем: пропущенные блоки догрузятся со следующим заголовком.
+	for _, block := range batch.Blocks {
+		blockCache.Add(block)
+	}
+	if fetchErr != nil {
+		return fetchErr
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"from": from,
+		"to":   to,
+	}).Debug("Новые блоки добавлены в кэш")
+	return nil
+}
+
+// confirmBlock помечает подтверждённым блок head-depth, если закэшированная
+// цепочка от головы до него непрерывна.
+func confirmBlock(blockCache *cache.BlockCache, head, depth int64) {
+	child, ok := blockCache.GetByNumber(models.BlockNumber(head))
+	if !ok {
+		return
+	}
+	for n := head - 1; n >= head-depth; n-- {
+		block, ok := blockCache.GetByNumber(models.BlockNumber(n))
+		if !ok || !strings.EqualFold(child.ParentHash, block.Hash) {
+			return
+		}
+		child = block
+	}
+	if child.Confirmed {
+		return
+	}
+	confirmed := *child
+	confirmed.Confirmed = true
+	blockCache.Add(&confirmed)
+}
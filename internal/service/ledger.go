@@ -0,0 +1,234 @@
+package service
+
+import (
+	"eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ledger накапливает чистое изменение баланса по каждому адресу
+// и отдельно сумму сожжённой базовой комиссии (EIP-1559).
+type ledger struct {
+	entries         map[string]*ledgerEntry
+	burned          *big.Int
+	withdrawals     *big.Int
+	withdrawalCount int
+}
+
+type ledgerEntry struct {
+	delta       *big.Int
+	withdrawals *big.Int
+	txCount     int
+	firstBlock  int64
+	lastBlock   int64
+	hasBlocks   bool
+}
+
+func newLedger() *ledger {
+	return &ledger{
+		entries:     make(map[string]*ledgerEntry),
+		burned:      new(big.Int),
+		withdrawals: new(big.Int),
+	}
+}
+
+func (l *ledger) entry(address string) *ledgerEntry {
+	address = strings.ToLower(address)
+	e, ok := l.entries[address]
+	if !ok {
+		e = &ledgerEntry{delta: new(big.Int), withdrawals: new(big.Int)}
+		l.entries[address] = e
+	}
+	return e
+}
+
+func (l *ledger) add(address string, amount *big.Int) {
+	if address == "" || amount.Sign() == 0 {
+		return
+	}
+	e := l.entry(address)
+	e.delta.Add(e.delta, amount)
+}
+
+// touch учитывает участие адреса в транзакции из блока blockNumber.
+func (l *ledger) touch(address string, blockNumber int64) {
+	if address == "" {
+		return
+	}
+	l.seen(address, blockNumber)
+	l.entry(address).txCount++
+}
+
+// seen расширяет диапазон блоков, в которых менялся баланс адреса.
+func (l *ledger) seen(address string, blockNumber int64) {
+	e := l.entry(address)
+	if !e.hasBlocks || blockNumber < e.firstBlock {
+		e.firstBlock = blockNumber
+	}
+	if !e.hasBlocks || blockNumber > e.lastBlock {
+		e.lastBlock = blockNumber
+	}
+	e.hasBlocks = true
+}
+
+func (l *ledger) credit(address string, amount *big.Int) {
+	l.add(address, amount)
+}
+
+func (l *ledger) debit(address string, amount *big.Int) {
+	l.add(address, new(big.Int).Neg(amount))
+}
+
+// applyTransaction списывает сумму и комиссию с отправителя, зачисляет сумму получателю,
+// а чаевые (effectiveGasPrice - baseFee) — получателю комиссии блока.
+// В режиме трассировки вместо Value учитываются все переводы из дерева вызовов.
+// У отменённой транзакции переводов нет, списывается только комиссия.
+// Value транзакции, создающей контракт, зачисляется созданному контракту.
+func (l *ledger) applyTransaction(tx models.TransactionData) {
+	participants := map[string]struct{}{
+		strings.ToLower(tx.From):        {},
+		strings.ToLower(tx.Recipient()): {},
+	}
+	switch {
+	case tx.Status == models.TxStatusFailed:
+		// Значение и внутренние переводы откатились вместе с транзакцией.
+	case tx.Traced:
+		for _, transfer := range tx.Transfers {
+			l.applyTransfer(transfer)
+			participants[strings.ToLower(transfer.From)] = struct{}{}
+			participants[strings.ToLower(transfer.To)] = struct{}{}
+		}
+	default:
+		l.applyTransfer(models.Transfer{From: tx.From, To: tx.Recipient(), Value: tx.Value})
+	}
+	blockNumber := hexOrZero(tx.BlockNumber).Int64()
+	for address := range participants {
+		l.touch(address, blockNumber)
+	}
+
+	fee, burned, tip := txFees(tx)
+	if fee.Sign() == 0 {
+		return
+	}
+	l.debit(tx.From, fee)
+	l.burned.Add(l.burned, burned)
+	if tip.Sign() > 0 && tx.Miner != "" {
+		l.credit(tx.Miner, tip)
+		l.seen(tx.Miner, blockNumber)
+	}
+}
+
+// txFees возвращает комиссию транзакции (gasUsed * effectiveGasPrice), её сожжённую
+// часть (gasUsed * baseFee, не больше комиссии) и чаевые получателю комиссии блока.
+func txFees(tx models.TransactionData) (fee, burned, tip *big.Int) {
+	gasUsed := hexOrZero(tx.GasUsed)
+	fee = new(big.Int).Mul(gasUsed, hexOrZero(tx.EffectiveGasPrice))
+	burned = new(big.Int).Mul(gasUsed, hexOrZero(tx.BaseFeePerGas))
+	if burned.Cmp(fee) > 0 {
+		burned.Set(fee)
+	}
+	return fee, burned, new(big.Int).Sub(fee, burned)
+}
+
+// applyWithdrawal зачисляет адресу вывод со стейкинга.
+func (l *ledger) applyWithdrawal(w models.WithdrawalData) {
+	amount := hexOrZero(w.Amount)
+	l.withdrawalCount++
+	if amount.Sign() == 0 {
+		return
+	}
+	l.credit(w.Address, amount)
+	e := l.entry(w.Address)
+	e.withdrawals.Add(e.withdrawals, amount)
+	l.withdrawals.Add(l.withdrawals, amount)
+	l.seen(w.Address, hexOrZero(w.BlockNumber).Int64())
+}
+
+func (l *ledger) applyTransfer(transfer models.Transfer) {
+	value := hexOrZero(transfer.Value)
+	l.debit(transfer.From, value)
+	l.credit(transfer.To, value)
+}
+
+func hexOrZero(hexStr string) *big.Int {
+	hexStr = util.TrimQuotes(hexStr)
+	if len(hexStr) < 3 {
+		return new(big.Int)
+	}
+	return util.HexToBigInt(hexStr)
+}
+
+// max возвращает адрес с наибольшим по модулю чистым изменением и само изменение со знаком.
+func (l *ledger) max() (string, *big.Int) {
+	top := l.top(1, models.DirectionAbs)
+	if len(top) == 0 {
+		return "", big.NewInt(0)
+	}
+	return top[0].address, new(big.Int).Set(top[0].delta)
+}
+
+type rankedEntry struct {
+	address string
+	*ledgerEntry
+}
+
+// top возвращает до n адресов, упорядоченных по изменению баланса в направлении direction:
+// increase — наибольшие поступления, decrease — наибольшие списания, abs — по модулю.
+func (l *ledger) top(n int, direction string) []rankedEntry {
+	ranked := make([]rankedEntry, 0, len(l.entries))
+	for address, e := range l.entries {
+		switch direction {
+		case models.DirectionIncrease:
+			if e.delta.Sign() <= 0 {
+				continue
+			}
+		case models.DirectionDecrease:
+			if e.delta.Sign() >= 0 {
+				continue
+			}
+		default:
+			if e.delta.Sign() == 0 {
+				continue
+			}
+		}
+		ranked = append(ranked, rankedEntry{address: address, ledgerEntry: e})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		var cmp int
+		switch direction {
+		case models.DirectionIncrease:
+			cmp = ranked[i].delta.Cmp(ranked[j].delta)
+		case models.DirectionDecrease:
+			cmp = ranked[j].delta.Cmp(ranked[i].delta)
+		default:
+			cmp = ranked[i].delta.CmpAbs(ranked[j].delta)
+		}
+		if cmp != 0 {
+			return cmp > 0
+		}
+		return ranked[i].address < ranked[j].address
+	})
+	if n >= 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+func buildLedger(transactionsSet *sync.Map) (*ledger, int) {
+	l := newLedger()
+	count := 0
+	transactionsSet.Range(func(key, value interface{}) bool {
+		switch data := value.(type) {
+		case models.TransactionData:
+			l.applyTransaction(data)
+			count++
+		case models.WithdrawalData:
+			l.applyWithdrawal(data)
+		}
+		return true
+	})
+	return l, count
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"eth_bal/internal/models"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+)
+
+const (
+	_alice = "0x00000000000000000000000000000000000000a1"
+	_bob   = "0x00000000000000000000000000000000000000b2"
+	_carol = "0x00000000000000000000000000000000000000c3"
+	_miner = "0x00000000000000000000000000000000000000ee"
+)
+
+func TestLedgerApplyTransaction(t *testing.T) {
+	tests := []struct {
+		name   string
+		tx     models.TransactionData
+		deltas map[string]int64
+		burned int64
+	}{
+		{
+			name:   "value without receipt",
+			tx:     models.TransactionData{From: _alice, To: _bob, Value: "0x64", BlockNumber: "0x1"},
+			deltas: map[string]int64{_alice: -100, _bob: 100},
+		},
+		{
+			name: "fee split into burn and tip",
+			tx: models.TransactionData{From: _alice, To: _bob, Value: "0x64", GasUsed: "0xa",
+				EffectiveGasPrice: "0x5", BaseFeePerGas: "0x3", Miner: _miner, BlockNumber: "0x1"},
+			// комиссия 10*5 = 50: 30 сжигается, 20 — чаевые майнеру
+			deltas: map[string]int64{_alice: -150, _bob: 100, _miner: 20},
+			burned: 30,
+		},
+		{
+			name: "legacy block without base fee",
+			tx: models.TransactionData{From: _alice, To: _bob, Value: "0x0", GasUsed: "0xa",
+				EffectiveGasPrice: "0x5", Miner: _miner, BlockNumber: "0x1"},
+			deltas: map[string]int64{_alice: -50, _miner: 50},
+		},
+		{
+			name: "burn capped by fee",
+			tx: models.TransactionData{From: _alice, To: _bob, GasUsed: "0xa",
+				EffectiveGasPrice: "0x2", BaseFeePerGas: "0x3", Miner: _miner, BlockNumber: "0x1"},
+			deltas: map[string]int64{_alice: -20},
+			burned: 20,
+		},
+		{
+			name: "self transfer pays only fee",
+			tx: models.TransactionData{From: _alice, To: _alice, Value: "0x64", GasUsed: "0x1",
+				EffectiveGasPrice: "0x1", Miner: _miner, BlockNumber: "0x1"},
+			deltas: map[string]int64{_alice: -1, _miner: 1},
+		},
+		{
+			name: "traced transfers replace value",
+			tx: models.TransactionData{From: _alice, To: _bob, Value: "0x64", BlockNumber: "0x1", Traced: true,
+				Transfers: []models.Transfer{
+					{From: _alice, To: _bob, Value: "0x64"},
+					{From: _bob, To: _carol, Value: "0x28"},
+				}},
+			deltas: map[string]int64{_alice: -100, _bob: 60, _carol: 40},
+		},
+		{
+			name: "contract creation credits created contract",
+			tx: models.TransactionData{From: _alice, ContractAddress: _carol, Value: "0x64", GasUsed: "0x1",
+				EffectiveGasPrice: "0x1", Miner: _miner, BlockNumber: "0x1"},
+			deltas: map[string]int64{_alice: -101, _carol: 100, _miner: 1},
+		},
+		{
+			name: "reverted transaction pays only fee",
+			tx: models.TransactionData{From: _alice, To: _bob, Value: "0x64", GasUsed: "0xa",
+				EffectiveGasPrice: "0x5", BaseFeePerGas: "0x3", Miner: _miner, BlockNumber: "0x1",
+				Status: models.TxStatusFailed},
+			deltas: map[string]int64{_alice: -50, _miner: 20},
+			burned: 30,
+		},
+		{
+			name: "reverted traced transaction ignores transfers",
+			tx: models.TransactionData{From: _alice, To: _bob, Value: "0x64", BlockNumber: "0x1",
+				Status: models.TxStatusFailed, Traced: true,
+				Transfers: []models.Transfer{{From: _alice, To: _bob, Value: "0x64"}}},
+			deltas: map[string]int64{},
+		},
+		{
+			name:   "receipt without status counts as success",
+			tx:     models.TransactionData{From: _alice, To: _bob, Value: "0x64", BlockNumber: "0x1", Status: ""},
+			deltas: map[string]int64{_alice: -100, _bob: 100},
+		},
+		{
+			name:   "addresses are case insensitive",
+			tx:     models.TransactionData{From: "0x00000000000000000000000000000000000000A1", To: _bob, Value: "0x1", BlockNumber: "0x1"},
+			deltas: map[string]int64{_alice: -1, _bob: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLedger()
+			l.applyTransaction(tt.tx)
+			assertDeltas(t, l, tt.deltas)
+			if l.burned.Cmp(big.NewInt(tt.burned)) != 0 {
+				t.Errorf("burned = %s, want %d", l.burned, tt.burned)
+			}
+		})
+	}
+}
+
+func TestLedgerBlockRange(t *testing.T) {
+	l := newLedger()
+	l.applyTransaction(models.TransactionData{From: _alice, To: _bob, Value: "0x1", BlockNumber: "0x7"})
+	l.applyTransaction(models.TransactionData{From: _bob, To: _carol, Value: "0x1", BlockNumber: "0x3"})
+	l.applyWithdrawal(models.WithdrawalData{Index: "0x1", Address: _bob, Amount: "0x5", BlockNumber: "0x9"})
+
+	e := l.entries[_bob]
+	if e.firstBlock != 3 || e.lastBlock != 9 {
+		t.Errorf("bob blocks = %d..%d, want 3..9", e.firstBlock, e.lastBlock)
+	}
+	if e.txCount != 2 {
+		t.Errorf("bob txCount = %d, want 2", e.txCount)
+	}
+	if e.withdrawals.Int64() != 5 || l.withdrawals.Int64() != 5 || l.withdrawalCount != 1 {
+		t.Errorf("withdrawals = %s (total %s, count %d), want 5", e.withdrawals, l.withdrawals, l.withdrawalCount)
+	}
+}
+
+func TestLedgerTop(t *testing.T) {
+	l := newLedger()
+	l.credit(_alice, big.NewInt(50))
+	l.debit(_bob, big.NewInt(70))
+	l.credit(_carol, big.NewInt(50))
+	l.debit(_miner, big.NewInt(10))
+	l.credit("0x00000000000000000000000000000000000000ff", big.NewInt(0))
+
+	tests := []struct {
+		direction string
+		n         int
+		want      []string
+	}{
+		{models.DirectionIncrease, 10, []string{_alice, _carol}},
+		{models.DirectionDecrease, 10, []string{_bob, _miner}},
+		{models.DirectionAbs, 10, []string{_bob, _alice, _carol, _miner}},
+		{models.DirectionAbs, 2, []string{_bob, _alice}},
+		{models.DirectionIncrease, 0, []string{}},
+	}
+	for _, tt := range tests {
+		top := l.top(tt.n, tt.direction)
+		got := make([]string, len(top))
+		for i, e := range top {
+			got[i] = e.address
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("top(%d, %s) = %v, want %v", tt.n, tt.direction, got, tt.want)
+		}
+	}
+
+	address, delta := l.max()
+	if address != _bob || delta.Int64() != -70 {
+		t.Errorf("max() = %s %s, want %s -70", address, delta, _bob)
+	}
+}
+
+func TestBuildLedger(t *testing.T) {
+	set := &sync.Map{}
+	set.Store("0x01", models.TransactionData{From: _alice, To: _bob, Value: "0xa", BlockNumber: "0x1"})
+	set.Store("0x02", models.TransactionData{From: _bob, To: _carol, Value: "0x3", BlockNumber: "0x2"})
+	set.Store("withdrawal:0x1", models.WithdrawalData{Index: "0x1", Address: _carol, Amount: "0x7", BlockNumber: "0x2"})
+
+	l, count := buildLedger(set)
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	assertDeltas(t, l, map[string]int64{_alice: -10, _bob: 7, _carol: 10})
+}
+
+func assertDeltas(t *testing.T, l *ledger, want map[string]int64) {
+	t.Helper()
+	for address, delta := range want {
+		e, ok := l.entries[address]
+		if !ok {
+			t.Errorf("%s: no entry, want %d", address, delta)
+			continue
+		}
+		if e.delta.Cmp(big.NewInt(delta)) != 0 {
+			t.Errorf("%s: delta %s, want %d", address, e.delta, delta)
+		}
+	}
+	for address, e := range l.entries {
+		if _, ok := want[address]; !ok && e.delta.Sign() != 0 {
+			t.Errorf("%s: unexpected delta %s", address, e.delta)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"eth_bal/configs"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"net/http"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewRPCClient создаёт клиента JSON-RPC с пулом из провайдера rpc и резервных rpc_providers.
+func NewRPCClient(cfg *configs.Config) (*jsonrpc.Client, error) {
+	primary := cfg.RPC
+	if primary.APIKey == "" {
+		primary.APIKey = getAPIKey(cfg)
+	}
+	var providers []*jsonrpc.Provider
+	for _, rpc := range append([]configs.RPC{primary}, cfg.RPCProviders...) {
+		provider, err := newProvider(rpc)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	opts := []jsonrpc.ClientOption{
+		jsonrpc.Breaker(cfg.CircuitBreaker.Threshold, cfg.CircuitBreaker.OpenTimeout),
+		jsonrpc.RetryBudget(cfg.RetryBudget.Ratio, cfg.RetryBudget.Reserve),
+		jsonrpc.Retry(retryPolicy(cfg.Retry), retryMethods(cfg)),
+	}
+	if cfg.Hedge.Enabled {
+		opts = append(opts, jsonrpc.Hedge(cfg.Hedge.Percentile, cfg.Hedge.MinDelay, cfg.Hedge.Methods...))
+	}
+	return jsonrpc.NewClient(createHTTPClient(cfg), providers, opts...)
+}
+
+// retryPolicy переводит секцию retry конфигурации в политику повторов.
+func retryPolicy(retry configs.Retry) util.RetryPolicy {
+	return util.RetryPolicy{
+		Attempts:   retry.Attempts,
+		Delay:      retry.Delay,
+		MaxDelay:   retry.MaxDelay,
+		MaxElapsed: retry.MaxElapsed,
+		Jitter:     retry.Jitter,
+	}
+}
+
+// retryMethods возвращает политики методов из retry_methods с подставленными
+// из retry незаданными полями.
+func retryMethods(cfg *configs.Config) map[string]util.RetryPolicy {
+	methods := make(map[string]util.RetryPolicy, len(cfg.RetryMethods))
+	for method := range cfg.RetryMethods {
+		methods[method] = retryPolicy(cfg.RetryFor(method))
+	}
+	return methods
+}
+
+// NewHeadSubscriber создаёт подписку на newHeads по секции ws; nil, если ws.url не задан.
+func NewHeadSubscriber(cfg *configs.Config) (*jsonrpc.HeadSubscriber, error) {
+	if cfg.WS.URL == "" {
+		return nil, nil
+	}
+	provider, err := newProvider(configs.RPC{
+		Name:       "ws",
+		URL:        cfg.WS.URL,
+		Auth:       cfg.WS.Auth,
+		AuthHeader: cfg.WS.AuthHeader,
+		APIKey:     getAPIKey(cfg),
+		Headers:    cfg.WS.Headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Пока заголовки приходят, каждый моложе head_max_age; дольше тишины
+	// соединение считается оборванным.
+	return jsonrpc.NewHeadSubscriber(provider, jsonrpc.ReadTimeout(cfg.WS.HeadMaxAge)), nil
+}
+
+func newProvider(rpc configs.RPC) (*jsonrpc.Provider, error) {
+	provider, err := jsonrpc.NewProvider(rpc.URL,
+		jsonrpc.Name(rpc.Name),
+		jsonrpc.Auth(jsonrpc.AuthStyle(rpc.Auth), rpc.APIKey, rpc.AuthHeader),
+		jsonrpc.Headers(rpc.Headers),
+		jsonrpc.RateLimit(rpc.RateLimit, rpc.RateBurst),
+		jsonrpc.MethodCosts(rpc.MethodCosts),
+	)
+	if err != nil {
+		return nil, err
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"provider": provider.Name(),
+		"url":      provider.String(),
+		"auth":     rpc.Auth,
+		"rate":     rpc.RateLimit,
+	}).Info("RPC provider configured")
+	return provider, nil
+}
+
+func getAPIKey(cfg *configs.Config) string {
+	apiKey := cfg.RPC.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("GETBLOCK_API_KEY")
+	}
+	return apiKey
+}
+
+func createHTTPClient(cfg *configs.Config) *http.Client {
+	return &http.Client{
+		Timeout: cfg.HTTPClientTimeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        cfg.MaxIdleConns,
+			MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+			IdleConnTimeout:     cfg.IdleConnTimeout,
+		},
+	}
+}
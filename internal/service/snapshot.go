@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/usecase/webapi"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LoadSnapshot загружает в кэш блоков снимок cfg.Snapshot.Path. Снимок
+// отклоняется с cache.ErrSnapshotMismatch, если он снят не с сети chainID или его
+// голова больше не в канонической цепи. Отсутствие файла ошибкой не считается.
+func LoadSnapshot(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, chainID string) error {
+	if cfg.Snapshot.Path == "" {
+		return nil
+	}
+	snapshot, err := cache.OpenSnapshot(cfg.Snapshot.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	header := snapshot.Header
+	if !strings.EqualFold(chainID, header.ChainID) {
+		return fmt.Errorf("%w: chain id %s, node is on %s", cache.ErrSnapshotMismatch, header.ChainID, chainID)
+	}
+	if header.Blocks > 0 {
+		head, err := webapi.GetBlockHeader(ctx, client, header.Head.Hex())
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(head.Hash, header.HeadHash) {
+			return fmt.Errorf("%w: block %d is %s, snapshot has %s", cache.ErrSnapshotMismatch, header.Head, head.Hash, header.HeadHash)
+		}
+	}
+
+	blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+	loaded := 0
+	for {
+		block, err := snapshot.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %w", cfg.Snapshot.Path, err)
+		}
+		blockCache.Restore(block)
+		loaded++
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"path":    cfg.Snapshot.Path,
+		"blocks":  loaded,
+		"head":    header.Head,
+		"created": header.CreatedAt,
+	}).Info("Снимок кэша загружен")
+	return nil
+}
+
+// SaveSnapshot сохраняет содержимое кэша блоков в cfg.Snapshot.Path снимком
+// сети chainID. К узлу не обращается: вызывается при остановке, когда он может
+// быть уже недоступен.
+func SaveSnapshot(cfg *configs.Config, chainID string) error {
+	if cfg.Snapshot.Path == "" {
+		return nil
+	}
+	if chainID == "" {
+		return errors.New("chain id is unknown, snapshot is not saved")
+	}
+	header, err := cache.WriteSnapshot(cfg.Snapshot.Path, chainID, cache.GetGlobalBlockCache(cfg.CacheSize).Blocks())
+	if err != nil {
+		return err
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"path":   cfg.Snapshot.Path,
+		"blocks": header.Blocks,
+		"head":   header.Head,
+	}).Info("Снимок кэша сохранён")
+	return nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestSnapshot(t *testing.T, path string) {
+	t.Helper()
+	blocks := []*models.Block{
+		{Number: "0x1", Hash: "0xb1", ParentHash: "0xb0"},
+		{Number: "0x2", Hash: "0xb2", ParentHash: "0xb1"},
+	}
+	if _, err := cache.WriteSnapshot(path, "0x1", blocks); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		chainID  string
+		nodeHash string
+		wantErr  error
+		// wantCalls — запросов к узлу: голова проверяется только после chain id.
+		wantCalls int
+	}{
+		{name: "matching chain and head", chainID: "0x1", nodeHash: "0xb2", wantCalls: 1},
+		{name: "chain id mismatch", chainID: "0x5", nodeHash: "0xb2", wantErr: cache.ErrSnapshotMismatch},
+		{name: "head replaced by reorg", chainID: "0x1", nodeHash: "0xbad", wantErr: cache.ErrSnapshotMismatch, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &configs.Config{CacheSize: 16, Snapshot: configs.Snapshot{Path: filepath.Join(t.TempDir(), "cache.snapshot.gz")}}
+			writeTestSnapshot(t, cfg.Snapshot.Path)
+			node, client := newTestNode(t, 2, func(int64) string { return tt.nodeHash })
+			blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+			blockCache.Purge(false)
+			defer blockCache.Purge(false)
+
+			err := LoadSnapshot(context.Background(), cfg, client, tt.chainID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoadSnapshot error = %v, want %v", err, tt.wantErr)
+			}
+			if got := node.count(""); got != tt.wantCalls {
+				t.Errorf("%d requests to the node, want %d", got, tt.wantCalls)
+			}
+			wantBlocks := 0
+			if tt.wantErr == nil {
+				wantBlocks = 2
+			}
+			if got := blockCache.Size(); got != wantBlocks {
+				t.Errorf("%d blocks restored, want %d", got, wantBlocks)
+			}
+		})
+	}
+}
+
+func TestLoadSnapshotMissingFile(t *testing.T) {
+	cfg := &configs.Config{CacheSize: 16, Snapshot: configs.Snapshot{Path: filepath.Join(t.TempDir(), "absent.gz")}}
+	node, client := newTestNode(t, 2, nil)
+	if err := LoadSnapshot(context.Background(), cfg, client, "0x1"); err != nil {
+		t.Fatalf("missing snapshot: %v", err)
+	}
+	if node.count("") != 0 {
+		t.Error("node queried without a snapshot")
+	}
+}
+
+func TestSaveSnapshot(t *testing.T) {
+	cfg := &configs.Config{CacheSize: 16, Snapshot: configs.Snapshot{Path: filepath.Join(t.TempDir(), "cache.snapshot.gz")}}
+	blockCache := cache.GetGlobalBlockCache(cfg.CacheSize)
+	blockCache.Purge(false)
+	defer blockCache.Purge(false)
+	blockCache.Add(&models.Block{Number: "0x2", Hash: "0xb2", ParentHash: "0xb1"})
+
+	if err := SaveSnapshot(cfg, ""); err == nil {
+		t.Fatal("snapshot saved without a chain id")
+	}
+	if _, err := os.Stat(cfg.Snapshot.Path); !os.IsNotExist(err) {
+		t.Errorf("snapshot file written without a chain id: %v", err)
+	}
+
+	if err := SaveSnapshot(cfg, "0x1"); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := cache.OpenSnapshot(cfg.Snapshot.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	if h := snapshot.Header; h.ChainID != "0x1" || h.Head != 2 || h.HeadHash != "0xb2" || h.Blocks != 1 {
+		t.Errorf("saved header %+v", h)
+	}
+}
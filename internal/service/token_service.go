@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"eth_bal/internal/usecase/webapi"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TokenChecker находит адрес с максимальным изменением баланса токена в том же окне блоков, что и EthChecker.
+func TokenChecker(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, params models.CheckParams, token string) (models.TokenResultBlock, error) {
+	window, err := resolveWindow(ctx, client, cfg, params)
+	if err != nil {
+		return models.TokenResultBlock{}, err
+	}
+	ledgers, err := collectTokenLedgers(ctx, client, cfg, window, token)
+	if err != nil {
+		return models.TokenResultBlock{}, err
+	}
+	l, ok := ledgers[strings.ToLower(token)]
+	if !ok {
+		l = newLedger()
+	}
+	return tokenResult(ctx, client, strings.ToLower(token), l, window), nil
+}
+
+// TopTokenMovers возвращает адрес с максимальным изменением баланса для каждого токена, встреченного в окне.
+func TopTokenMovers(ctx context.Context, cfg *configs.Config, client *jsonrpc.Client, params models.CheckParams) ([]models.TokenResultBlock, error) {
+	window, err := resolveWindow(ctx, client, cfg, params)
+	if err != nil {
+		return nil, err
+	}
+	ledgers, err := collectTokenLedgers(ctx, client, cfg, window, "")
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]string, 0, len(ledgers))
+	for token := range ledgers {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	results := make([]models.TokenResultBlock, 0, len(tokens))
+	for _, token := range tokens {
+		results = append(results, tokenResult(ctx, client, token, ledgers[token], window))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	log.Logger.WithField("tokens", len(results)).Info("Изменения балансов токенов рассчитаны")
+	return results, nil
+}
+
+func collectTokenLedgers(ctx context.Context, client *jsonrpc.Client, cfg *configs.Config, window blockWindow, token string) (map[string]*ledger, error) {
+	logs, err := webapi.GetTransferLogs(ctx, client, window.from, window.to, cfg.Logs.ChunkSize, cfg.Logs.BatchSize, token)
+	if err != nil {
+		log.Logger.WithError(err).Warn("Не удалось загрузить логи Transfer")
+		return nil, err
+	}
+
+	ledgers := make(map[string]*ledger)
+	for _, entry := range logs {
+		// ERC-721 использует ту же сигнатуру, но tokenId передаётся четвёртым топиком.
+		if entry.Removed || len(entry.Topics) != 3 {
+			continue
+		}
+		address := strings.ToLower(entry.Address)
+		l, ok := ledgers[address]
+		if !ok {
+			l = newLedger()
+			ledgers[address] = l
+		}
+		l.applyTransfer(models.Transfer{
+			From:  topicToAddress(entry.Topics[1]),
+			To:    topicToAddress(entry.Topics[2]),
+			Value: entry.Data,
+		})
+	}
+	log.Logger.WithFields(logrus.Fields{
+		"logs":   len(logs),
+		"tokens": len(ledgers),
+	}).Info("Логи Transfer обработаны")
+	return ledgers, nil
+}
+
+func tokenResult(ctx context.Context, client *jsonrpc.Client, token string, l *ledger, window blockWindow) models.TokenResultBlock {
+	info := getTokenInfo(ctx, client, token)
+	maxAddress, maxDelta := l.max()
+	sign := models.DirectionIncrease
+	if maxDelta.Sign() < 0 {
+		sign = models.DirectionDecrease
+	}
+	change := new(big.Int).Abs(maxDelta)
+	return models.TokenResultBlock{
+		Token:           token,
+		Symbol:          info.Symbol,
+		Decimals:        info.Decimals,
+		DecimalsUnknown: !info.DecimalsKnown,
+		Address:         maxAddress,
+		// Без decimals сумма остаётся в минимальных единицах (Decimals = 0).
+		Change:    util.ToUnits(change, info.Decimals),
+		ChangeRaw: change.String(),
+		Sign:      sign,
+		From:      window.from,
+		To:        window.to,
+	}
+}
+
+func getTokenInfo(ctx context.Context, client *jsonrpc.Client, token string) models.TokenInfo {
+	tokenCache := cache.GetGlobalTokenCache()
+	if info, ok := tokenCache.Get(token); ok {
+		return info
+	}
+	info, err := webapi.GetTokenInfo(ctx, client, token)
+	if err != nil {
+		log.Logger.WithFields(logrus.Fields{
+			"token": token,
+			"error": err.Error(),
+		}).Warn("Не удалось получить метаданные токена")
+		// Частично полученные метаданные не кэшируются, decimals считаются неизвестными.
+		info.Decimals, info.DecimalsKnown = 0, false
+		return info
+	}
+	tokenCache.Add(info)
+	return info
+}
+
+func topicToAddress(topic string) string {
+	if len(topic) < 40 {
+		return ""
+	}
+	return "0x" + strings.ToLower(topic[len(topic)-40:])
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"eth_bal/internal/usecase/webapi"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidRange возвращается, если запрошенное окно блоков некорректно.
+var ErrInvalidRange = errors.New("invalid block range")
+
+// blockWindow — окно анализа [from, to] включительно и голова цепи на момент запроса.
+// toHash — хэш последнего блока окна, на котором основан результат.
+type blockWindow struct {
+	from   int64
+	to     int64
+	toHash string
+	head   int64
+}
+
+func (w blockWindow) contains(blockNumber int64) bool {
+	return blockNumber >= w.from && blockNumber <= w.to
+}
+
+// resolveWindow переводит параметры запроса в номера блоков и проверяет их
+// относительно головы цепи и cfg.MaxBlockRange. При живой подписке newHeads
+// голова цепи берётся из кэша без запроса к узлу.
+func resolveWindow(ctx context.Context, client *jsonrpc.Client, cfg *configs.Config, params models.CheckParams) (blockWindow, error) {
+	var window blockWindow
+	liveHead, liveHash, live := cache.GetGlobalBlockCache(cfg.CacheSize).Head(cfg.WS.HeadMaxAge)
+	if params.To == "" || params.To == models.BlockTagLatest {
+		if live {
+			window.head, window.to, window.toHash = int64(liveHead), int64(liveHead), liveHash
+		} else {
+			header, err := webapi.GetBlockHeader(ctx, client, models.BlockTagLatest)
+			if err != nil {
+				return window, err
+			}
+			window.head = util.HexToInt(header.Number)
+			window.to, window.toHash = window.head, header.Hash
+		}
+	} else {
+		window.head = int64(liveHead)
+		if !live {
+			head, err := getLatestBlockNumber(ctx, client)
+			if err != nil {
+				return window, err
+			}
+			window.head = head
+		}
+		to, err := resolveBlock(ctx, client, params.To, window.head)
+		if err != nil {
+			return window, err
+		}
+		if to <= window.head {
+			header, err := webapi.GetBlockHeader(ctx, client, util.IntToHex(to))
+			if err != nil {
+				return window, err
+			}
+			window.toHash = header.Hash
+		}
+		window.to = to
+	}
+
+	var err error
+	if params.From != "" {
+		if params.Blocks != 0 {
+			return window, fmt.Errorf("%w: from and blocks are mutually exclusive", ErrInvalidRange)
+		}
+		if window.from, err = resolveBlock(ctx, client, params.From, window.head); err != nil {
+			return window, err
+		}
+	} else {
+		blocks := params.Blocks
+		if blocks == 0 {
+			blocks = cfg.BlocksToAnalyze
+		}
+		if blocks < 0 {
+			return window, fmt.Errorf("%w: blocks must be positive", ErrInvalidRange)
+		}
+		window.from = window.to - blocks + 1
+	}
+
+	switch {
+	case window.from < 0:
+		return window, fmt.Errorf("%w: window starts before genesis", ErrInvalidRange)
+	case window.from > window.to:
+		return window, fmt.Errorf("%w: from %d is after to %d", ErrInvalidRange, window.from, window.to)
+	case window.to > window.head:
+		return window, fmt.Errorf("%w: to %d is ahead of head %d", ErrInvalidRange, window.to, window.head)
+	case cfg.MaxBlockRange > 0 && window.to-window.from+1 > cfg.MaxBlockRange:
+		return window, fmt.Errorf("%w: range of %d blocks exceeds maximum of %d", ErrInvalidRange, window.to-window.from+1, cfg.MaxBlockRange)
+	}
+
+	log.Logger.WithFields(logrus.Fields{
+		"from": window.from,
+		"to":   window.to,
+		"head": window.head,
+		"hash": window.toHash,
+	}).Info("Окно анализа определено")
+	return window, nil
+}
+
+func resolveBlock(ctx context.Context, client *jsonrpc.Client, value string, head int64) (int64, error) {
+	switch value {
+	case "", models.BlockTagLatest:
+		return head, nil
+	case models.BlockTagSafe, models.BlockTagFinalized:
+		header, err := webapi.GetBlockHeader(ctx, client, value)
+		if err != nil {
+			return 0, err
+		}
+		return util.HexToInt(header.Number), nil
+	}
+	n, err := util.ParseBlockNumber(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is neither a block number nor a supported tag", ErrInvalidRange, value)
+	}
+	return n, nil
+}
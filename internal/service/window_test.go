@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"eth_bal/configs"
+	"eth_bal/internal/cache"
+	"eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testNode — узел с головой head: safe отстаёт от неё на 32 блока, finalized — на 64.
+// Хэш блока задаёт hash, по умолчанию — "0xh" и номер в десятичной записи.
+type testNode struct {
+	head int64
+	hash func(number int64) string
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newTestNode(t *testing.T, head int64, hash func(number int64) string) (*testNode, *jsonrpc.Client) {
+	t.Helper()
+	if hash == nil {
+		hash = func(number int64) string { return "0xh" + strconv.FormatInt(number, 10) }
+	}
+	node := &testNode{head: head, hash: hash, calls: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(node.serve(t)))
+	t.Cleanup(server.Close)
+	provider, err := jsonrpc.NewProvider(server.URL, jsonrpc.Auth(jsonrpc.AuthNone, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc.NewClient(server.Client(), []*jsonrpc.Provider{provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node, client
+}
+
+func (n *testNode) serve(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request models.JSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+		n.mu.Lock()
+		n.calls[request.Method]++
+		n.mu.Unlock()
+		var result any
+		switch request.Method {
+		case "eth_blockNumber":
+			result = util.IntToHex(n.head)
+		case "eth_getBlockByNumber":
+			number := n.head
+			switch tag := request.Params[0].(string); tag {
+			case models.BlockTagLatest:
+			case models.BlockTagSafe:
+				number -= 32
+			case models.BlockTagFinalized:
+				number -= 64
+			default:
+				number = util.HexToInt(tag)
+			}
+			result = models.Block{Number: util.IntToHex(number), Hash: n.hash(number)}
+		default:
+			t.Errorf("unexpected method %s", request.Method)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result})
+	}
+}
+
+// count возвращает число запросов method; без method — всех запросов.
+func (n *testNode) count(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if method != "" {
+		return n.calls[method]
+	}
+	total := 0
+	for _, calls := range n.calls {
+		total += calls
+	}
+	return total
+}
+
+func TestResolveWindow(t *testing.T) {
+	const head = 1000
+	tests := []struct {
+		name     string
+		params   models.CheckParams
+		maxRange int64
+		want     blockWindow
+		wantErr  bool
+	}{
+		{name: "default window", want: blockWindow{from: 901, to: 1000, toHash: "0xh1000", head: head}},
+		{name: "blocks", params: models.CheckParams{Blocks: 10}, want: blockWindow{from: 991, to: 1000, toHash: "0xh1000", head: head}},
+		{name: "latest tag", params: models.CheckParams{To: "latest", Blocks: 5}, want: blockWindow{from: 996, to: 1000, toHash: "0xh1000", head: head}},
+		{name: "decimal to", params: models.CheckParams{To: "900", Blocks: 10}, want: blockWindow{from: 891, to: 900, toHash: "0xh900", head: head}},
+		{name: "hex to", params: models.CheckParams{To: "0x384", Blocks: 10}, want: blockWindow{from: 891, to: 900, toHash: "0xh900", head: head}},
+		{name: "decimal from, hex to", params: models.CheckParams{From: "800", To: "0x352"}, want: blockWindow{from: 800, to: 850, toHash: "0xh850", head: head}},
+		{name: "safe tag", params: models.CheckParams{To: "safe", Blocks: 1}, want: blockWindow{from: 968, to: 968, toHash: "0xh968", head: head}},
+		{name: "finalized from", params: models.CheckParams{From: "finalized"}, want: blockWindow{from: 936, to: 1000, toHash: "0xh1000", head: head}},
+		{name: "range at maximum", params: models.CheckParams{Blocks: 50}, maxRange: 50, want: blockWindow{from: 951, to: 1000, toHash: "0xh1000", head: head}},
+		{name: "range over maximum", params: models.CheckParams{Blocks: 51}, maxRange: 50, wantErr: true},
+		{name: "from and blocks", params: models.CheckParams{From: "900", Blocks: 10}, wantErr: true},
+		{name: "negative blocks", params: models.CheckParams{Blocks: -1}, wantErr: true},
+		{name: "to ahead of head", params: models.CheckParams{To: "1001", Blocks: 1}, wantErr: true},
+		{name: "from after to", params: models.CheckParams{From: "950", To: "900"}, wantErr: true},
+		{name: "before genesis", params: models.CheckParams{Blocks: 2000}, wantErr: true},
+		{name: "unsupported tag", params: models.CheckParams{To: "pending"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestNode(t, head, nil)
+			cfg := &configs.Config{CacheSize: 16, BlocksToAnalyze: 100, MaxBlockRange: tt.maxRange}
+			got, err := resolveWindow(context.Background(), client, cfg, tt.params)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRange) {
+					t.Fatalf("error = %v, want ErrInvalidRange", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("window %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResolveWindowLiveHead: при живой подписке newHeads голова берётся из кэша.
+func TestResolveWindowLiveHead(t *testing.T) {
+	cfg := &configs.Config{CacheSize: 16, BlocksToAnalyze: 100, WS: configs.WS{HeadMaxAge: time.Minute}}
+	cache.GetGlobalBlockCache(cfg.CacheSize).SetHead(1200, "0xlive")
+
+	node, client := newTestNode(t, 1000, nil)
+	got, err := resolveWindow(context.Background(), client, cfg, models.CheckParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (blockWindow{from: 1101, to: 1200, toHash: "0xlive", head: 1200}); got != want {
+		t.Errorf("window %+v, want %+v", got, want)
+	}
+	if n := node.count(""); n != 0 {
+		t.Errorf("%d requests to the node with a live head", n)
+	}
+
+	// Явный to проверяется по голове из кэша, у узла запрашивается только хэш блока.
+	got, err = resolveWindow(context.Background(), client, cfg, models.CheckParams{To: "1150", Blocks: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (blockWindow{from: 1141, to: 1150, toHash: "0xh1150", head: 1200}); got != want {
+		t.Errorf("window %+v, want %+v", got, want)
+	}
+	if n := node.count("eth_blockNumber"); n != 0 {
+		t.Errorf("eth_blockNumber called %d times with a live head", n)
+	}
+
+	// Устаревшая голова не используется.
+	cfg.WS.HeadMaxAge = 0
+	got, err = resolveWindow(context.Background(), client, cfg, models.CheckParams{Blocks: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.head != 1000 {
+		t.Errorf("head %d from a stale subscription, want the node head 1000", got.head)
+	}
+}
+
+func TestResolveBlock(t *testing.T) {
+	node, client := newTestNode(t, 1000, nil)
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 777, false},
+		{"latest", 777, false},
+		{"safe", 968, false},
+		{"finalized", 936, false},
+		{"123", 123, false},
+		{"0x7b", 123, false},
+		{"0X7B", 123, false},
+		{"pending", 0, true},
+		{"-5", 0, true},
+		{"0xzz", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := resolveBlock(context.Background(), client, tt.value, 777)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidRange) {
+				t.Errorf("resolveBlock(%q) error = %v, want ErrInvalidRange", tt.value, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveBlock(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+	// latest берётся из переданной головы, тег safe и finalized — у узла.
+	if n := node.count("eth_getBlockByNumber"); n != 2 {
+		t.Errorf("%d header requests, want 2 for safe and finalized", n)
+	}
+}
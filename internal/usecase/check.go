@@ -1,23 +1,152 @@
 package usecase
 
 import (
+	"context"
 	"eth_bal/configs"
 	"eth_bal/internal/models"
 	"eth_bal/internal/service"
+	"eth_bal/internal/usecase/webapi"
+	"eth_bal/pkg/jsonrpc"
+	"sync"
 )
 
+// ErrInvalidRange возвращается, если окно блоков в параметрах запроса некорректно.
+var ErrInvalidRange = service.ErrInvalidRange
+
+// IncompleteWindowError возвращается, если часть блоков окна не удалось загрузить.
+type IncompleteWindowError = service.IncompleteWindowError
+
+// ErrPrefetchRunning возвращается, если предыдущая предзагрузка кэша ещё идёт.
+var ErrPrefetchRunning = service.ErrPrefetchRunning
+
 type CheckBlock interface {
-	Check() models.ResultBlock
+	Check(ctx context.Context, params models.CheckParams) (models.ResultBlock, error)
+	CheckToken(ctx context.Context, params models.CheckParams, token string) (models.TokenResultBlock, error)
+	TopTokens(ctx context.Context, params models.CheckParams) ([]models.TokenResultBlock, error)
+	Top(ctx context.Context, params models.CheckParams, n int, direction string) ([]models.AddressChange, error)
+	AddressChanges(ctx context.Context, params models.CheckParams, address string) (models.AddressChanges, error)
+	Providers() []jsonrpc.ProviderHealth
+	RetryBudget() jsonrpc.RetryBudgetStats
+	// Ingest поддерживает кэш по подписке newHeads до отмены ctx; без ws.url сразу возвращается.
+	Ingest(ctx context.Context)
+	// LoadSnapshot загружает кэш блоков из снимка и запоминает идентификатор сети,
+	// SaveSnapshot сохраняет кэш с этим идентификатором, не обращаясь к узлу.
+	LoadSnapshot(ctx context.Context) error
+	SaveSnapshot() error
+	// Методы администрирования кэша блоков.
+	CacheStatus() service.CacheStatus
+	PurgeCache(withStore bool) int
+	EvictBlocks(from, to models.BlockNumber, withStore bool) int
+	Prefetch(ctx context.Context, params models.CheckParams) (service.PrefetchStatus, error)
+	// Stop прерывает фоновые задачи (предзагрузку кэша) и ждёт их завершения.
+	Stop()
 }
 
 type checkblock struct {
-	cfg *configs.Config
+	cfg    *configs.Config
+	client *jsonrpc.Client
+	heads  *jsonrpc.HeadSubscriber
+	// sizer подбирает размер пакетов загрузки блоков для всех проверок.
+	sizer *service.BatchSizer
+	// chainID узла, полученный при LoadSnapshot; пуст, если снимки отключены.
+	chainID string
+
+	// ctx живёт до Stop; от него наследуют контекст фоновые задачи из jobs.
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   sync.WaitGroup
+}
+
+func New(cfg *configs.Config) (CheckBlock, error) {
+	client, err := service.NewRPCClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	heads, err := service.NewHeadSubscriber(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &checkblock{cfg: cfg, client: client, heads: heads, sizer: service.NewBatchSizer(cfg), ctx: ctx, cancel: cancel}, nil
+}
+
+func (t *checkblock) Check(ctx context.Context, params models.CheckParams) (models.ResultBlock, error) {
+	return service.EthChecker(ctx, t.cfg, t.client, t.sizer, params)
+}
+
+func (t *checkblock) CheckToken(ctx context.Context, params models.CheckParams, token string) (models.TokenResultBlock, error) {
+	return service.TokenChecker(ctx, t.cfg, t.client, params, token)
+}
+
+func (t *checkblock) TopTokens(ctx context.Context, params models.CheckParams) ([]models.TokenResultBlock, error) {
+	return service.TopTokenMovers(ctx, t.cfg, t.client, params)
+}
+
+func (t *checkblock) Top(ctx context.Context, params models.CheckParams, n int, direction string) ([]models.AddressChange, error) {
+	return service.TopChanges(ctx, t.cfg, t.client, t.sizer, params, n, direction)
+}
+
+func (t *checkblock) AddressChanges(ctx context.Context, params models.CheckParams, address string) (models.AddressChanges, error) {
+	return service.AddressChanges(ctx, t.cfg, t.client, t.sizer, params, address)
+}
+
+func (t *checkblock) Providers() []jsonrpc.ProviderHealth {
+	return t.client.Health()
+}
+
+func (t *checkblock) RetryBudget() jsonrpc.RetryBudgetStats {
+	return t.client.RetryBudget()
+}
+
+func (t *checkblock) Ingest(ctx context.Context) {
+	if t.heads == nil {
+		return
+	}
+	service.IngestHeads(ctx, t.cfg, t.client, t.heads)
+}
+
+func (t *checkblock) LoadSnapshot(ctx context.Context) error {
+	if t.cfg.Snapshot.Path == "" {
+		return nil
+	}
+	chainID, err := webapi.GetChainID(ctx, t.client)
+	if err != nil {
+		return err
+	}
+	t.chainID = chainID
+	return service.LoadSnapshot(ctx, t.cfg, t.client, chainID)
+}
+
+func (t *checkblock) SaveSnapshot() error {
+	return service.SaveSnapshot(t.cfg, t.chainID)
+}
+
+func (t *checkblock) CacheStatus() service.CacheStatus {
+	return service.CacheStats(t.cfg)
+}
+
+func (t *checkblock) PurgeCache(withStore bool) int {
+	return service.PurgeCache(t.cfg, withStore)
+}
+
+func (t *checkblock) EvictBlocks(from, to models.BlockNumber, withStore bool) int {
+	return service.EvictBlocks(t.cfg, from, to, withStore)
 }
 
-func New(cfg *configs.Config) CheckBlock {
-	return &checkblock{cfg: cfg}
+func (t *checkblock) Prefetch(ctx context.Context, params models.CheckParams) (service.PrefetchStatus, error) {
+	status, job, err := service.Prefetch(ctx, t.cfg, t.client, t.sizer, params)
+	if err != nil {
+		return status, err
+	}
+	t.jobs.Add(1)
+	go func() {
+		defer t.jobs.Done()
+		job(t.ctx)
+	}()
+	return status, nil
 }
 
-func (t *checkblock) Check() models.ResultBlock {
-	return service.EthChecker(t.cfg)
+func (t *checkblock) Stop() {
+	t.cancel()
+	t.jobs.Wait()
 }
@@ -0,0 +1,41 @@
+package webapi
+
+import (
+	"context"
+	"eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GetChainID возвращает идентификатор сети (eth_chainId) в виде 0x-строки.
+func GetChainID(ctx context.Context, client *jsonrpc.Client) (string, error) {
+	var result string
+	err := client.RetryPolicy("eth_chainId").Do(ctx, func() error {
+		request := models.JSONRPCRequest{
+			JSONRPC: "2.0",
+			Method:  "eth_chainId",
+			Params:  []any{},
+			ID:      1,
+		}
+		var response models.JSONRPCResponse
+		if err := client.SendJSONRPCRequest(ctx, request, &response); err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"method": "eth_chainId",
+				"error":  err.Error(),
+			}).Error("Failed to fetch chain id")
+			return err
+		}
+		if response.Error != nil {
+			return jsonrpc.ResponseError("eth_chainId", response)
+		}
+		result = util.TrimQuotes(string(response.Result))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package webapi
+
+import (
+	"eth_bal/internal/models"
+	"eth_bal/pkg/jsonrpc"
+)
+
+// compactHeader — блок без полных транзакций (fullTx=false): вместо объектов
+// транзакций узел возвращает их хэши.
+type compactHeader struct {
+	jsonrpc.CompactBlock
+	Transactions []jsonrpc.Hash `json:"transactions"`
+}
+
+// blockFromResult переводит результат eth_getBlockByNumber в модель; nil — блок не найден.
+func blockFromResult(result any) *models.Block {
+	switch result := result.(type) {
+	case **jsonrpc.CompactBlock:
+		if *result != nil {
+			return blockFromCompact(*result)
+		}
+	case **compactHeader:
+		if header := *result; header != nil {
+			block := blockFromCompact(&header.CompactBlock)
+			block.Transactions = make([]models.Transaction, len(header.Transactions))
+			for i, hash := range header.Transactions {
+				block.Transactions[i] = models.Transaction{Hash: hash.Hex(), BlockNumber: block.Number}
+			}
+			return block
+		}
+	}
+	return nil
+}
+
+// blockFromCompact переводит компактный блок в модель кэша и сервисов.
+func blockFromCompact(b *jsonrpc.CompactBlock) *models.Block {
+	block := &models.Block{
+		Number:       b.Number.Hex(),
+		Hash:         b.Hash.Hex(),
+		ParentHash:   b.ParentHash.Hex(),
+		Miner:        b.Miner.Hex(),
+		GasUsed:      b.GasUsed.Hex(),
+		Transactions: make([]models.Transaction, len(b.Transactions)),
+	}
+	if b.BaseFeePerGas != nil {
+		block.BaseFeePerGas = b.BaseFeePerGas.Hex()
+	}
+	for i := range b.Transactions {
+		tx := &b.Transactions[i]
+		block.Transactions[i] = models.Transaction{
+			Hash:             tx.Hash.Hex(),
+			From:             tx.From.Hex(),
+			Value:            tx.Value.Hex(),
+			Gas:              tx.Gas.Hex(),
+			GasPrice:         tx.GasPrice.Hex(),
+			BlockNumber:      block.Number,
+			TransactionIndex: tx.TransactionIndex.Hex(),
+		}
+		if tx.To != nil {
+			block.Transactions[i].To = tx.To.Hex()
+		}
+	}
+	if len(b.Withdrawals) > 0 {
+		block.Withdrawals = make([]models.Withdrawal, len(b.Withdrawals))
+		for i, w := range b.Withdrawals {
+			block.Withdrawals[i] = models.Withdrawal{
+				Index:          w.Index.Hex(),
+				ValidatorIndex: w.ValidatorIndex.Hex(),
+				Address:        w.Address.Hex(),
+				Amount:         w.Amount.Hex(),
+			}
+		}
+	}
+	return block
+}
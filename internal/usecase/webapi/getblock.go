@@ -1,27 +1,21 @@
 package webapi
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"eth_bal/internal/models"
 	"eth_bal/internal/util"
 	"eth_bal/pkg/jsonrpc"
 	"eth_bal/pkg/log"
-	"net/http"
-	"sync"
+	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
-const (
-	_attempts = 5
-	_delay    = 1 * time.Second
-)
-
-func GetLatestBlockNumber(client *http.Client, apiKey string) (string, error) {
+func GetLatestBlockNumber(ctx context.Context, client *jsonrpc.Client) (string, error) {
 	var result string
-	err := util.RetryWithBackoff(_attempts, _delay, func() error {
+	err := client.RetryPolicy("eth_blockNumber").Do(ctx, func() error {
 		request := models.JSONRPCRequest{
 			JSONRPC: "2.0",
 			Method:  "eth_blockNumber",
@@ -30,7 +24,7 @@ func GetLatestBlockNumber(client *http.Client, apiKey string) (string, error) {
 		}
 		var response models.JSONRPCResponse
 		start := time.Now()
-		if err := jsonrpc.SendJSONRPCRequest(client, apiKey, request, &response); err != nil {
+		if err := client.SendJSONRPCRequest(ctx, request, &response); err != nil {
 			log.Logger.WithFields(logrus.Fields{
 				"method":  "eth_blockNumber",
 				"attempt": "retried",
@@ -50,7 +44,7 @@ func GetLatestBlockNumber(client *http.Client, apiKey string) (string, error) {
 				"code":    response.Error.Code,
 				"message": response.Error.Message,
 			}).Warn("API responded with error")
-			return errors.New(response.Error.Message)
+			return jsonrpc.ResponseError("eth_blockNumber", response)
 		}
 		result = util.TrimQuotes(string(response.Result))
 		return nil
@@ -61,11 +55,57 @@ func GetLatestBlockNumber(client *http.Client, apiKey string) (string, error) {
 	return result, nil
 }
 
-func GetBlocksByNumbers(client *http.Client, apiKey string, blockNumbers []string, fullTx bool) ([]*models.Block, error) {
-	var blocks []*models.Block
-	err := util.RetryWithBackoff(_attempts, _delay, func() error {
-		requests := make([]models.JSONRPCRequest, len(blockNumbers))
-		for i, blockNumber := range blockNumbers {
+// BlockBatch — результат пакетной загрузки блоков. Blocks содержит загруженные
+// блоки в порядке запроса, Failed — номера блоков, не загруженных после всех
+// попыток, с последней ошибкой по каждому. Bytes — суммарный размер результатов
+// загруженных блоков, Latency — время первого, полноразмерного запроса пакета;
+// по ним подбирается размер следующих пакетов.
+type BlockBatch struct {
+	Blocks  []*models.Block
+	Failed  map[string]error
+	Bytes   int64
+	Latency time.Duration
+}
+
+// BatchError возвращается вместе с BlockBatch, если часть блоков не загружена.
+type BatchError struct {
+	Failed map[string]error
+	Total  int
+}
+
+// Retryable: пакет стоит повторить, если временна хотя бы одна из ошибок.
+func (e *BatchError) Retryable() bool {
+	for _, err := range e.Failed {
+		if jsonrpc.Retryable(err) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *BatchError) Error() string {
+	for blockNumber, err := range e.Failed {
+		return fmt.Sprintf("failed to fetch %d of %d blocks (block %s: %v)", len(e.Failed), e.Total, blockNumber, err)
+	}
+	return fmt.Sprintf("failed to fetch blocks: %d requested", e.Total)
+}
+
+// GetBlocksByNumbers загружает блоки пакетными запросами. Ответ разбирается
+// потоково сразу в компактные структуры, без промежуточного json.RawMessage.
+// Ответы сопоставляются с запросами по ID, повторно запрашиваются только блоки,
+// вернувшие ошибку.
+// При частичной неудаче возвращает загруженные блоки и *BatchError.
+func GetBlocksByNumbers(ctx context.Context, client *jsonrpc.Client, blockNumbers []string, fullTx bool) (BlockBatch, error) {
+	loaded := make(map[string]*models.Block, len(blockNumbers))
+	failed := make(map[string]error)
+	pending := append([]string(nil), blockNumbers...)
+	var (
+		bytes   int64
+		latency time.Duration
+	)
+	err := client.RetryPolicy("eth_getBlockByNumber").Do(ctx, func() error {
+		requests := make([]models.JSONRPCRequest, len(pending))
+		for i, blockNumber := range pending {
 			requests[i] = models.JSONRPCRequest{
 				JSONRPC: "2.0",
 				Method:  "eth_getBlockByNumber",
@@ -74,31 +114,106 @@ func GetBlocksByNumbers(client *http.Client, apiKey string, blockNumbers []strin
 			}
 		}
 
-		var responses []models.JSONRPCResponse
-		if err := jsonrpc.SendBatchJSONRPCRequest(client, apiKey, requests, &responses); err != nil {
+		results := jsonrpc.NewBatchResults(func() any {
+			if fullTx {
+				return new(*jsonrpc.CompactBlock)
+			}
+			return new(*compactHeader)
+		})
+		start := time.Now()
+		err := client.SendBatchStream(ctx, requests, results)
+		if latency == 0 {
+			latency = time.Since(start)
+		}
+		if err != nil {
+			for _, blockNumber := range pending {
+				failed[blockNumber] = err
+			}
 			return err
 		}
 
-		blocks = make([]*models.Block, len(responses))
-		var wg sync.WaitGroup
-		for i, response := range responses {
-			wg.Add(1)
-			go func(i int, response models.JSONRPCResponse) {
-				defer wg.Done()
-				var block models.Block
-				if err := json.Unmarshal(response.Result, &block); err != nil {
-					blocks = nil
-					return
-				}
-				blocks[i] = &block
-			}(i, response)
+		items, errs := jsonrpc.MatchItems(requests, results.Items)
+		var retry []string
+		for i, blockNumber := range pending {
+			id := int64(i + 1)
+			if err, ok := errs[id]; ok {
+				failed[blockNumber] = err
+				retry = append(retry, blockNumber)
+				continue
+			}
+			block := blockFromResult(items[id].Result)
+			if block == nil {
+				failed[blockNumber] = fmt.Errorf("block %s not found", blockNumber)
+				retry = append(retry, blockNumber)
+				continue
+			}
+			loaded[blockNumber] = block
+			bytes += items[id].Bytes
+			delete(failed, blockNumber)
+		}
+		if len(retry) > 0 {
+			log.Logger.WithFields(logrus.Fields{
+				"method": "eth_getBlockByNumber",
+				"failed": len(retry),
+				"total":  len(pending),
+			}).Warn("Часть блоков пакета не загружена, повторяем только их")
+		}
+		pending = retry
+		if len(pending) > 0 {
+			return &BatchError{Failed: failed, Total: len(blockNumbers)}
+		}
+		return nil
+	})
+
+	batch := BlockBatch{Blocks: make([]*models.Block, 0, len(loaded)), Bytes: bytes, Latency: latency}
+	for _, blockNumber := range blockNumbers {
+		if block, ok := loaded[blockNumber]; ok {
+			batch.Blocks = append(batch.Blocks, block)
 		}
-		wg.Wait()
+	}
+	if err == nil {
+		return batch, nil
+	}
+	batch.Failed = make(map[string]error, len(pending))
+	for _, blockNumber := range pending {
+		batch.Failed[blockNumber] = failed[blockNumber]
+	}
+	if ctx.Err() != nil {
+		return batch, ctx.Err()
+	}
+	return batch, &BatchError{Failed: batch.Failed, Total: len(blockNumbers)}
+}
 
+// GetBlockHeader возвращает заголовок блока (без транзакций) по номеру в hex
+// или по метке (latest, safe, finalized).
+func GetBlockHeader(ctx context.Context, client *jsonrpc.Client, blockNumberOrTag string) (*models.Block, error) {
+	var block *models.Block
+	err := client.RetryPolicy("eth_getBlockByNumber").Do(ctx, func() error {
+		request := models.JSONRPCRequest{
+			JSONRPC: "2.0",
+			Method:  "eth_getBlockByNumber",
+			Params:  []any{blockNumberOrTag, false},
+			ID:      1,
+		}
+		var response models.JSONRPCResponse
+		if err := client.SendJSONRPCRequest(ctx, request, &response); err != nil {
+			log.Logger.WithFields(logrus.Fields{
+				"method": "eth_getBlockByNumber",
+				"block":  blockNumberOrTag,
+				"error":  err.Error(),
+			}).Error("Failed to fetch block header")
+			return err
+		}
+		if err := json.Unmarshal(response.Result, &block); err != nil {
+			return err
+		}
+		if block == nil || block.Number == "" {
+			return fmt.Errorf("block %q not found", blockNumberOrTag)
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	return blocks, nil
+	return block, nil
 }
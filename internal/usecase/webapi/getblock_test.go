@@ -0,0 +1,116 @@
+package webapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"eth_bal/internal/models"
+	"eth_bal/pkg/jsonrpc"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// _benchBatchSize — число блоков в пакете бенчмарков, как у пакетов analyzeBlocks.
+const _benchBatchSize = 20
+
+// loadBatchFixture собирает пакетный ответ eth_getBlockByNumber из блока mainnet-формы
+// (180 транзакций типа 2 с input, 16 выводов) в testdata.
+func loadBatchFixture(tb testing.TB) ([]models.JSONRPCRequest, []byte) {
+	tb.Helper()
+	file, err := os.Open("testdata/block_full_tx.json.gz")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	block, err := io.ReadAll(gz)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	requests := make([]models.JSONRPCRequest, _benchBatchSize)
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i := range requests {
+		id := int64(i + 1)
+		requests[i] = models.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_getBlockByNumber", Params: []any{"0x1406f40", true}, ID: id}
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.WriteString(`{"jsonrpc":"2.0","id":` + strconv.FormatInt(id, 10) + `,"result":`)
+		body.Write(block)
+		body.WriteByte('}')
+	}
+	body.WriteByte(']')
+	return requests, body.Bytes()
+}
+
+func newFixtureClient(b *testing.B) (*jsonrpc.Client, []models.JSONRPCRequest) {
+	requests, body := loadBatchFixture(b)
+	b.SetBytes(int64(len(body)))
+	client := newTestClient(b, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+	return client, requests
+}
+
+// BenchmarkBatchRawMessage — прежний путь: весь ответ в []JSONRPCResponse
+// с json.RawMessage, затем повторный разбор каждого result в models.Block.
+func BenchmarkBatchRawMessage(b *testing.B) {
+	client, requests := newFixtureClient(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var responses []models.JSONRPCResponse
+		if err := client.SendBatchJSONRPCRequest(ctx, requests, &responses); err != nil {
+			b.Fatal(err)
+		}
+		matched, errs := jsonrpc.MatchBatch(requests, responses)
+		if len(errs) > 0 {
+			b.Fatal(errs)
+		}
+		for _, response := range matched {
+			var block models.Block
+			if err := json.Unmarshal(response.Result, &block); err != nil {
+				b.Fatal(err)
+			}
+			if len(block.Transactions) == 0 {
+				b.Fatal("block without transactions")
+			}
+		}
+	}
+}
+
+// BenchmarkBatchStream — SendBatchStream: result каждого элемента разбирается
+// сразу в CompactBlock и переводится в models.Block.
+func BenchmarkBatchStream(b *testing.B) {
+	client, requests := newFixtureClient(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results := jsonrpc.NewBatchResults(func() any { return new(*jsonrpc.CompactBlock) })
+		if err := client.SendBatchStream(ctx, requests, results); err != nil {
+			b.Fatal(err)
+		}
+		items, errs := jsonrpc.MatchItems(requests, results.Items)
+		if len(errs) > 0 {
+			b.Fatal(errs)
+		}
+		for _, item := range items {
+			block := blockFromResult(item.Result)
+			if block == nil || len(block.Transactions) == 0 {
+				b.Fatal("block without transactions")
+			}
+		}
+	}
+}
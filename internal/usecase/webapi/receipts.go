@@ -0,0 +1,156 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"eth_bal/internal/models"
+	"eth_bal/pkg/jsonrpc"
+	"eth_bal/pkg/log"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FillReceipts дополняет транзакции блоков данными квитанций (gasUsed, effectiveGasPrice, status).
+// Сначала пробует eth_getBlockReceipts, для блоков, где метод не сработал, запрашивает
+// квитанции по одной через eth_getTransactionReceipt.
+func FillReceipts(ctx context.Context, client *jsonrpc.Client, blocks []*models.Block) error {
+	var pending []*models.Block
+	for _, block := range blocks {
+		if block != nil && len(block.Transactions) > 0 {
+			pending = append(pending, block)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	receipts, err := getBlockReceipts(ctx, client, pending)
+	if err != nil {
+		log.Logger.WithError(err).Warn("eth_getBlockReceipts failed, falling back to eth_getTransactionReceipt")
+		receipts = make([][]models.Receipt, len(pending))
+	}
+
+	for i, block := range pending {
+		if receipts[i] == nil {
+			blockReceipts, err := getTransactionReceipts(ctx, client, block)
+			if err != nil {
+				log.Logger.WithFields(logrus.Fields{
+					"block_number": block.Number,
+					"error":        err.Error(),
+				}).Error("Failed to fetch transaction receipts")
+				return err
+			}
+			receipts[i] = blockReceipts
+		}
+		applyReceipts(block, receipts[i])
+	}
+	return nil
+}
+
+func getBlockReceipts(ctx context.Context, client *jsonrpc.Client, blocks []*models.Block) ([][]models.Receipt, error) {
+	result := make([][]models.Receipt, len(blocks))
+	err := client.RetryPolicy("eth_getBlockReceipts").Do(ctx, func() error {
+		requests := make([]models.JSONRPCRequest, len(blocks))
+		for i, block := range blocks {
+			requests[i] = models.JSONRPCRequest{
+				JSONRPC: "2.0",
+				Method:  "eth_getBlockReceipts",
+				Params:  []any{block.Number},
+				ID:      int64(i + 1),
+			}
+		}
+
+		var responses []models.JSONRPCResponse
+		if err := client.SendBatchJSONRPCRequest(ctx, requests, &responses); err != nil {
+			return err
+		}
+
+		for _, response := range responses {
+			i := int(response.ID) - 1
+			if i < 0 || i >= len(blocks) || response.Error != nil {
+				continue
+			}
+			var receipts []models.Receipt
+			if err := json.Unmarshal(response.Result, &receipts); err != nil {
+				continue
+			}
+			// null или неполный список квитанций блок дозапрашивает по одной.
+			if len(receipts) != len(blocks[i].Transactions) {
+				continue
+			}
+			result[i] = receipts
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func getTransactionReceipts(ctx context.Context, client *jsonrpc.Client, block *models.Block) ([]models.Receipt, error) {
+	var receipts []models.Receipt
+	err := client.RetryPolicy("eth_getTransactionReceipt").Do(ctx, func() error {
+		requests := make([]models.JSONRPCRequest, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			requests[i] = models.JSONRPCRequest{
+				JSONRPC: "2.0",
+				Method:  "eth_getTransactionReceipt",
+				Params:  []any{tx.Hash},
+				ID:      int64(i + 1),
+			}
+		}
+
+		var responses []models.JSONRPCResponse
+		if err := client.SendBatchJSONRPCRequest(ctx, requests, &responses); err != nil {
+			return err
+		}
+
+		results, errs := jsonrpc.MatchBatch(requests, responses)
+		receipts = make([]models.Receipt, 0, len(requests))
+		for i, request := range requests {
+			if err, ok := errs[request.ID]; ok {
+				return err
+			}
+			var receipt *models.Receipt
+			if err := json.Unmarshal(results[request.ID].Result, &receipt); err != nil {
+				return err
+			}
+			// null — узел ещё не знает квитанцию (отстающая реплика): без неё
+			// комиссия и статус транзакции неизвестны.
+			if receipt == nil || receipt.TransactionHash == "" {
+				return fmt.Errorf("receipt of transaction %s not found", block.Transactions[i].Hash)
+			}
+			receipts = append(receipts, *receipt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+func applyReceipts(block *models.Block, receipts []models.Receipt) {
+	byHash := make(map[string]models.Receipt, len(receipts))
+	for _, receipt := range receipts {
+		byHash[strings.ToLower(receipt.TransactionHash)] = receipt
+	}
+	for i := range block.Transactions {
+		tx := &block.Transactions[i]
+		receipt, ok := byHash[strings.ToLower(tx.Hash)]
+		if !ok {
+			continue
+		}
+		tx.GasUsed = receipt.GasUsed
+		tx.EffectiveGasPrice = receipt.EffectiveGasPrice
+		tx.Status = receipt.Status
+		tx.ContractAddress = receipt.ContractAddress
+		if tx.EffectiveGasPrice == "" {
+			// До London квитанции не содержат effectiveGasPrice.
+			tx.EffectiveGasPrice = tx.GasPrice
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"eth_bal/internal/models"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+const _createdContract = "0x00000000000000000000000000000000000000cc"
+
+func receiptsTestBlock() *models.Block {
+	return &models.Block{Number: "0x10", Transactions: []models.Transaction{
+		{Hash: "0x01", From: "0xa1", To: "0xb2", GasPrice: "0x3"},
+		{Hash: "0x02", From: "0xa1", GasPrice: "0x3"},
+	}}
+}
+
+func testReceipt(hash string) models.Receipt {
+	receipt := models.Receipt{TransactionHash: hash, GasUsed: "0x5208", EffectiveGasPrice: "0x3", Status: models.TxStatusSuccess}
+	if hash == "0x02" {
+		receipt.ContractAddress = _createdContract
+	}
+	return receipt
+}
+
+// receiptsHandler отвечает на eth_getBlockReceipts результатом blockReceipts,
+// а на eth_getTransactionReceipt — квитанцией или null, если nullReceipts.
+func receiptsHandler(t *testing.T, blockReceipts any, nullReceipts bool) (http.HandlerFunc, map[string]int) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requests []models.JSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Errorf("decode batch: %v", err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		responses := make([]map[string]any, len(requests))
+		for i, request := range requests {
+			calls[request.Method]++
+			var result any
+			switch request.Method {
+			case "eth_getBlockReceipts":
+				result = blockReceipts
+			case "eth_getTransactionReceipt":
+				if !nullReceipts {
+					result = testReceipt(request.Params[0].(string))
+				}
+			}
+			responses[i] = map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result}
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+	}, calls
+}
+
+func TestFillReceipts(t *testing.T) {
+	full := []models.Receipt{testReceipt("0x01"), testReceipt("0x02")}
+	tests := []struct {
+		name          string
+		blockReceipts any
+		nullReceipts  bool
+		wantErr       bool
+		wantFallback  bool
+	}{
+		{name: "block receipts", blockReceipts: full},
+		{name: "null block receipts fall back", blockReceipts: nil, wantFallback: true},
+		{name: "partial block receipts fall back", blockReceipts: full[:1], wantFallback: true},
+		{name: "null transaction receipt fails", blockReceipts: nil, nullReceipts: true, wantErr: true, wantFallback: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, calls := receiptsHandler(t, tt.blockReceipts, tt.nullReceipts)
+			client := newTestClient(t, handler)
+			block := receiptsTestBlock()
+			err := FillReceipts(context.Background(), client, []*models.Block{block})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FillReceipts error = %v, want error %v", err, tt.wantErr)
+			}
+			if fallback := calls["eth_getTransactionReceipt"] > 0; fallback != tt.wantFallback {
+				t.Errorf("fell back to eth_getTransactionReceipt: %v, want %v", fallback, tt.wantFallback)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, tx := range block.Transactions {
+				if tx.GasUsed != "0x5208" || tx.Status != models.TxStatusSuccess {
+					t.Errorf("%s: receipt not applied: %+v", tx.Hash, tx)
+				}
+			}
+			creation := block.Transactions[1]
+			if creation.ContractAddress != _createdContract || creation.Recipient() != _createdContract {
+				t.Errorf("contract creation recipient %q, want %s", creation.Recipient(), _createdContract)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package webapi
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// TransferTopic — keccak256("Transfer(address,address,uint256)").
+	TransferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+	_decimalsSelector = "0x313ce567"
+	_symbolSelector   = "0x95d89b41"
+)
+
+// GetTransferLogs возвращает логи Transfer в диапазоне блоков [fromBlock, toBlock].
+// Диапазон разбивается на части по chunkSize блоков, части отправляются пакетами
+// не больше batchSize запросов; при повторе запрашиваются только неудавшиеся части.
+// Если token пуст, возвращаются логи всех контрактов.
+func GetTransferLogs(ctx context.Context, client *jsonrpc.Client, fromBlock, toBlock, chunkSize int64, batchSize int, token string) ([]models.Log, error) {
+	chunkSize, batchSize = max(chunkSize, 1), max(batchSize, 1)
+	var requests []models.JSONRPCRequest
+	for from := fromBlock; from <= toBlock; from += chunkSize {
+		filter := map[string]any{
+			"fromBlock": util.IntToHex(from),
+			"toBlock":   util.IntToHex(min(from+chunkSize-1, toBlock)),
+			"topics":    []any{TransferTopic},
+		}
+		if token != "" {
+			filter["address"] = token
+		}
+		requests = append(requests, models.JSONRPCRequest{
+			JSONRPC: "2.0",
+			Method:  "eth_getLogs",
+			Params:  []any{filter},
+			ID:      int64(len(requests) + 1),
+		})
+	}
+
+	chunks := make([][]models.Log, len(requests))
+	pending := requests
+	err := client.RetryPolicy("eth_getLogs").Do(ctx, func() error {
+		var (
+			failed  []models.JSONRPCRequest
+			lastErr error
+		)
+		for start := 0; start < len(pending); start += batchSize {
+			batch := pending[start:min(start+batchSize, len(pending))]
+			var responses []models.JSONRPCResponse
+			if err := client.SendBatchJSONRPCRequest(ctx, batch, &responses); err != nil {
+				if ctx.Err() != nil || !jsonrpc.Retryable(err) {
+					return err
+				}
+				failed, lastErr = append(failed, batch...), err
+				continue
+			}
+			results, errs := jsonrpc.MatchBatch(batch, responses)
+			for _, request := range batch {
+				if err, ok := errs[request.ID]; ok {
+					failed, lastErr = append(failed, request), err
+					continue
+				}
+				var chunk []models.Log
+				if err := json.Unmarshal(results[request.ID].Result, &chunk); err != nil {
+					failed, lastErr = append(failed, request), err
+					continue
+				}
+				chunks[request.ID-1] = chunk
+			}
+		}
+		pending = failed
+		if len(failed) > 0 {
+			return fmt.Errorf("%d of %d eth_getLogs chunks failed: %w", len(failed), len(requests), lastErr)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var logs []models.Log
+	for _, chunk := range chunks {
+		logs = append(logs, chunk...)
+	}
+	return logs, nil
+}
+
+// GetTokenInfo запрашивает decimals() и symbol() контракта через eth_call.
+// DecimalsKnown ложно, если контракт не вернул decimals().
+func GetTokenInfo(ctx context.Context, client *jsonrpc.Client, token string) (models.TokenInfo, error) {
+	info := models.TokenInfo{Address: strings.ToLower(token)}
+	err := client.RetryPolicy("eth_call").Do(ctx, func() error {
+		requests := []models.JSONRPCRequest{
+			newEthCallRequest(token, _decimalsSelector, 1),
+			newEthCallRequest(token, _symbolSelector, 2),
+		}
+
+		var responses []models.JSONRPCResponse
+		if err := client.SendBatchJSONRPCRequest(ctx, requests, &responses); err != nil {
+			return err
+		}
+
+		for _, response := range responses {
+			if response.Error != nil {
+				// Контракт может не реализовывать необязательные методы ERC-20.
+				continue
+			}
+			var result string
+			if err := json.Unmarshal(response.Result, &result); err != nil {
+				return err
+			}
+			switch response.ID {
+			case 1:
+				info.Decimals, info.DecimalsKnown = decodeDecimals(result)
+			case 2:
+				info.Symbol = decodeSymbol(result)
+			}
+		}
+		return nil
+	})
+	return info, err
+}
+
+func newEthCallRequest(to, data string, id int64) models.JSONRPCRequest {
+	return models.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_call",
+		Params:  []any{map[string]any{"to": to, "data": data}, "latest"},
+		ID:      id,
+	}
+}
+
+func decodeDecimals(result string) (int, bool) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil || len(raw) < 32 {
+		return 0, false
+	}
+	decimals := new(big.Int).SetBytes(raw[:32])
+	if !decimals.IsInt64() || decimals.Int64() > 77 {
+		return 0, false
+	}
+	return int(decimals.Int64()), true
+}
+
+// decodeSymbol поддерживает ABI-строку и устаревший вариант bytes32 (например, MKR).
+func decodeSymbol(result string) string {
+	raw, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil || len(raw) < 32 {
+		return ""
+	}
+	if len(raw) >= 64 {
+		offset := new(big.Int).SetBytes(raw[:32])
+		if offset.IsInt64() && offset.Int64()+32 <= int64(len(raw)) {
+			start := offset.Int64()
+			length := new(big.Int).SetBytes(raw[start : start+32])
+			if length.IsInt64() && start+32+length.Int64() <= int64(len(raw)) {
+				return string(raw[start+32 : start+32+length.Int64()])
+			}
+		}
+	}
+	return strings.TrimRight(string(raw[:32]), "\x00")
+}
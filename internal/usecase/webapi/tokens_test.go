@@ -0,0 +1,104 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/jsonrpc"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient возвращает клиента, который отправляет запросы в handler
+// и повторяет их без заметных пауз.
+func newTestClient(t testing.TB, handler http.HandlerFunc) *jsonrpc.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	provider, err := jsonrpc.NewProvider(server.URL, jsonrpc.Auth(jsonrpc.AuthNone, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := jsonrpc.NewClient(server.Client(), []*jsonrpc.Provider{provider},
+		jsonrpc.Retry(util.RetryPolicy{Attempts: 5, Delay: time.Millisecond}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestGetTransferLogsRetriesFailedChunks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		calls    = make(map[string]int)
+		maxBatch int
+	)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var requests []models.JSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Errorf("decode batch: %v", err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		maxBatch = max(maxBatch, len(requests))
+		responses := make([]map[string]any, len(requests))
+		for i, request := range requests {
+			from := request.Params[0].(map[string]any)["fromBlock"].(string)
+			calls[from]++
+			if from == "0x6" && calls[from] == 1 {
+				responses[i] = map[string]any{"jsonrpc": "2.0", "id": request.ID,
+					"error": map[string]any{"code": -32000, "message": "query timeout"}}
+				continue
+			}
+			responses[i] = map[string]any{"jsonrpc": "2.0", "id": request.ID,
+				"result": []models.Log{{BlockNumber: from, Topics: []string{TransferTopic}}}}
+		}
+		json.NewEncoder(w).Encode(responses)
+	})
+
+	logs, err := GetTransferLogs(context.Background(), client, 0, 9, 2, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range logs {
+		got = append(got, entry.BlockNumber)
+	}
+	want := []string{"0x0", "0x2", "0x4", "0x6", "0x8"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("logs from %v, want %v", got, want)
+	}
+	for from, n := range calls {
+		if wantCalls := map[bool]int{true: 2, false: 1}[from == "0x6"]; n != wantCalls {
+			t.Errorf("chunk %s requested %d times, want %d", from, n, wantCalls)
+		}
+	}
+	if maxBatch > 2 {
+		t.Errorf("batch of %d requests, want at most 2", maxBatch)
+	}
+}
+
+func TestDecodeDecimals(t *testing.T) {
+	tests := []struct {
+		result string
+		want   int
+		ok     bool
+	}{
+		{"0x0000000000000000000000000000000000000000000000000000000000000012", 18, true},
+		{"0x0000000000000000000000000000000000000000000000000000000000000000", 0, true},
+		{"0x", 0, false},
+		{"0x00000000000000000000000000000000000000000000000000000000000000ff", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := decodeDecimals(tt.result)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("decodeDecimals(%s) = %d, %v, want %d, %v", tt.result, got, ok, tt.want, tt.ok)
+		}
+	}
+}
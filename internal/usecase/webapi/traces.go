@@ -0,0 +1,219 @@
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"eth_bal/configs"
+	"eth_bal/internal/models"
+	"eth_bal/pkg/jsonrpc"
+	"fmt"
+	"strings"
+)
+
+// FillTraces заполняет InternalTransfers транзакций блоков переводами ETH,
+// найденными в трассировке вызовов, и помечает блоки как Traced.
+func FillTraces(ctx context.Context, client *jsonrpc.Client, blocks []*models.Block, method string) error {
+	var pending []*models.Block
+	for _, block := range blocks {
+		if block != nil && !block.Traced {
+			pending = append(pending, block)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return client.RetryPolicy(traceRPCMethod(method)).Do(ctx, func() error {
+		requests := make([]models.JSONRPCRequest, len(pending))
+		for i, block := range pending {
+			requests[i] = newTraceRequest(block.Number, method, int64(i+1))
+		}
+
+		var responses []models.JSONRPCResponse
+		if err := client.SendBatchJSONRPCRequest(ctx, requests, &responses); err != nil {
+			return err
+		}
+		if len(responses) != len(pending) {
+			return fmt.Errorf("expected %d trace responses, got %d", len(pending), len(responses))
+		}
+
+		transfers := make([]map[string][]models.Transfer, len(pending))
+		for _, response := range responses {
+			i := int(response.ID) - 1
+			if i < 0 || i >= len(pending) {
+				return fmt.Errorf("unexpected trace response id %d", response.ID)
+			}
+			if response.Error != nil {
+				return jsonrpc.ResponseError(traceRPCMethod(method), response)
+			}
+			var err error
+			if method == configs.TraceMethodParity {
+				transfers[i], err = parseParityTraces(response.Result)
+			} else {
+				transfers[i], err = parseCallTraces(response.Result, pending[i])
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for i, block := range pending {
+			for j := range block.Transactions {
+				tx := &block.Transactions[j]
+				tx.InternalTransfers = transfers[i][strings.ToLower(tx.Hash)]
+			}
+			block.Traced = true
+		}
+		return nil
+	})
+}
+
+func newTraceRequest(blockNumber, method string, id int64) models.JSONRPCRequest {
+	if method == configs.TraceMethodParity {
+		return models.JSONRPCRequest{
+			JSONRPC: "2.0",
+			Method:  traceRPCMethod(method),
+			Params:  []any{blockNumber},
+			ID:      id,
+		}
+	}
+	return models.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  traceRPCMethod(method),
+		Params:  []any{blockNumber, map[string]any{"tracer": "callTracer"}},
+		ID:      id,
+	}
+}
+
+// traceRPCMethod возвращает метод JSON-RPC для метода трассировки из конфигурации.
+func traceRPCMethod(method string) string {
+	if method == configs.TraceMethodParity {
+		return "trace_block"
+	}
+	return "debug_traceBlockByNumber"
+}
+
+// parseCallTraces разбирает ответ debug_traceBlockByNumber. Результаты идут
+// в порядке транзакций блока; старые версии geth возвращают кадры без обёртки txHash/result.
+func parseCallTraces(raw json.RawMessage, block *models.Block) (map[string][]models.Transfer, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	if len(items) != len(block.Transactions) {
+		return nil, fmt.Errorf("block %s: expected %d traces, got %d", block.Number, len(block.Transactions), len(items))
+	}
+
+	result := make(map[string][]models.Transfer, len(items))
+	for i, item := range items {
+		var wrapped models.TxTraceResult
+		if err := json.Unmarshal(item, &wrapped); err != nil {
+			return nil, err
+		}
+		frame := wrapped.Result
+		if frame == nil && wrapped.Error == "" {
+			frame = &models.CallFrame{}
+			if err := json.Unmarshal(item, frame); err != nil {
+				return nil, err
+			}
+		}
+		if wrapped.Error != "" {
+			return nil, fmt.Errorf("trace of tx %s failed: %s", block.Transactions[i].Hash, wrapped.Error)
+		}
+		hash := strings.ToLower(block.Transactions[i].Hash)
+		result[hash] = flattenCallFrame(*frame, nil)
+	}
+	return result, nil
+}
+
+// flattenCallFrame собирает переводы ETH из дерева вызовов. Откатившиеся вызовы
+// пропускаются вместе с вложенными, DELEGATECALL и STATICCALL ETH не переводят.
+func flattenCallFrame(frame models.CallFrame, transfers []models.Transfer) []models.Transfer {
+	if frame.Error != "" {
+		return transfers
+	}
+	switch strings.ToUpper(frame.Type) {
+	case "CALL", "CREATE", "CREATE2", "SELFDESTRUCT":
+		if hasValue(frame.Value) {
+			transfers = append(transfers, models.Transfer{From: frame.From, To: frame.To, Value: frame.Value})
+		}
+	}
+	for _, call := range frame.Calls {
+		transfers = flattenCallFrame(call, transfers)
+	}
+	return transfers
+}
+
+// parseParityTraces разбирает плоский ответ trace_block.
+func parseParityTraces(raw json.RawMessage) (map[string][]models.Transfer, error) {
+	var traces []models.ParityTrace
+	if err := json.Unmarshal(raw, &traces); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]models.Transfer)
+	failed := make(map[string][][]int)
+	for _, trace := range traces {
+		if trace.TransactionHash == "" {
+			// Награды за блок не относятся к транзакциям.
+			continue
+		}
+		hash := strings.ToLower(trace.TransactionHash)
+		if _, ok := result[hash]; !ok {
+			result[hash] = nil
+		}
+		if trace.Error != "" {
+			failed[hash] = append(failed[hash], trace.TraceAddress)
+			continue
+		}
+		if underFailed(failed[hash], trace.TraceAddress) {
+			continue
+		}
+
+		var transfer models.Transfer
+		switch trace.Type {
+		case "call":
+			if trace.Action.CallType != "call" {
+				continue
+			}
+			transfer = models.Transfer{From: trace.Action.From, To: trace.Action.To, Value: trace.Action.Value}
+		case "create":
+			if trace.Result == nil {
+				continue
+			}
+			transfer = models.Transfer{From: trace.Action.From, To: trace.Result.Address, Value: trace.Action.Value}
+		case "suicide":
+			transfer = models.Transfer{From: trace.Action.Address, To: trace.Action.RefundAddress, Value: trace.Action.Balance}
+		default:
+			continue
+		}
+		if hasValue(transfer.Value) {
+			result[hash] = append(result[hash], transfer)
+		}
+	}
+	return result, nil
+}
+
+func underFailed(failed [][]int, traceAddress []int) bool {
+	for _, prefix := range failed {
+		if len(prefix) > len(traceAddress) {
+			continue
+		}
+		match := true
+		for i := range prefix {
+			if prefix[i] != traceAddress[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func hasValue(value string) bool {
+	value = strings.TrimLeft(strings.TrimPrefix(value, "0x"), "0")
+	return value != ""
+}
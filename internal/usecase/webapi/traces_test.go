@@ -0,0 +1,111 @@
+package webapi
+
+import (
+	"eth_bal/internal/models"
+	"reflect"
+	"testing"
+)
+
+const (
+	_txA = "0xaa"
+	_txB = "0xbb"
+)
+
+func TestParseCallTraces(t *testing.T) {
+	block := &models.Block{Number: "0x1", Transactions: []models.Transaction{{Hash: _txA}, {Hash: "0xBB"}}}
+	tests := []struct {
+		name    string
+		raw     string
+		want    map[string][]models.Transfer
+		wantErr bool
+	}{
+		{
+			name: "wrapped frames",
+			raw: `[
+				{"txHash":"0xaa","result":{"type":"CALL","from":"0x1","to":"0x2","value":"0x10","calls":[
+					{"type":"CALL","from":"0x2","to":"0x3","value":"0x4"},
+					{"type":"DELEGATECALL","from":"0x2","to":"0x4","value":"0x5"},
+					{"type":"STATICCALL","from":"0x2","to":"0x5","value":"0x0"}
+				]}},
+				{"txHash":"0xbb","result":{"type":"CALL","from":"0x1","to":"0x2","value":"0x0"}}
+			]`,
+			want: map[string][]models.Transfer{
+				_txA: {{From: "0x1", To: "0x2", Value: "0x10"}, {From: "0x2", To: "0x3", Value: "0x4"}},
+				_txB: nil,
+			},
+		},
+		{
+			name: "reverted subtree skipped",
+			raw: `[
+				{"result":{"type":"CALL","from":"0x1","to":"0x2","value":"0x10","calls":[
+					{"type":"CALL","from":"0x2","to":"0x3","value":"0x4","error":"execution reverted","calls":[
+						{"type":"CALL","from":"0x3","to":"0x4","value":"0x1"}
+					]},
+					{"type":"CREATE2","from":"0x2","to":"0x6","value":"0x2"}
+				]}},
+				{"result":{"type":"CALL","from":"0x1","to":"0x2","value":"0x1","error":"out of gas"}}
+			]`,
+			want: map[string][]models.Transfer{
+				_txA: {{From: "0x1", To: "0x2", Value: "0x10"}, {From: "0x2", To: "0x6", Value: "0x2"}},
+				_txB: nil,
+			},
+		},
+		{
+			name: "unwrapped frames from old geth",
+			raw:  `[{"type":"CALL","from":"0x1","to":"0x2","value":"0x3"},{"type":"CALL","from":"0x1","to":"0x2","value":"0x0"}]`,
+			want: map[string][]models.Transfer{
+				_txA: {{From: "0x1", To: "0x2", Value: "0x3"}},
+				_txB: nil,
+			},
+		},
+		{
+			name:    "trace count mismatch",
+			raw:     `[{"result":{"type":"CALL"}}]`,
+			wantErr: true,
+		},
+		{
+			name:    "trace error",
+			raw:     `[{"error":"tracer timeout"},{"result":{"type":"CALL"}}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCallTraces([]byte(tt.raw), block)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseParityTraces(t *testing.T) {
+	raw := `[
+		{"type":"call","action":{"callType":"call","from":"0x1","to":"0x2","value":"0x10"},"traceAddress":[],"transactionHash":"0xAA"},
+		{"type":"call","action":{"callType":"call","from":"0x2","to":"0x3","value":"0x4"},"traceAddress":[0],"transactionHash":"0xaa","error":"Reverted"},
+		{"type":"call","action":{"callType":"call","from":"0x3","to":"0x4","value":"0x1"},"traceAddress":[0,0],"transactionHash":"0xaa"},
+		{"type":"call","action":{"callType":"delegatecall","from":"0x2","to":"0x5","value":"0x7"},"traceAddress":[1],"transactionHash":"0xaa"},
+		{"type":"create","action":{"from":"0x2","value":"0x2"},"result":{"address":"0x6"},"traceAddress":[2],"transactionHash":"0xaa"},
+		{"type":"suicide","action":{"address":"0x6","refundAddress":"0x7","balance":"0x2"},"traceAddress":[3],"transactionHash":"0xaa"},
+		{"type":"call","action":{"callType":"call","from":"0x1","to":"0x2","value":"0x0"},"traceAddress":[],"transactionHash":"0xbb"},
+		{"type":"reward","action":{"author":"0x9","value":"0x1"},"traceAddress":[]}
+	]`
+	got, err := parseParityTraces([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]models.Transfer{
+		_txA: {
+			{From: "0x1", To: "0x2", Value: "0x10"},
+			{From: "0x2", To: "0x6", Value: "0x2"},
+			{From: "0x6", To: "0x7", Value: "0x2"},
+		},
+		_txB: nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
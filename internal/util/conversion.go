@@ -3,6 +3,8 @@ package util
 import (
 	"fmt"
 	"math/big"
+	"strconv"
+	"strings"
 )
 
 func HexToInt(hexStr string) int64 {
@@ -27,6 +29,42 @@ func WeiToEth(wei *big.Int) *big.Float {
 	return eth
 }
 
+// ParseBlockNumber разбирает номер блока в десятичном виде или в виде 0x-строки.
+func ParseBlockNumber(str string) (int64, error) {
+	base := 10
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+		str = str[2:]
+		base = 16
+	}
+	n, err := strconv.ParseInt(str, base, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative block number %d", n)
+	}
+	return n, nil
+}
+
+// ToUnits переводит сумму в минимальных единицах токена в человекочитаемые единицы.
+func ToUnits(amount *big.Int, decimals int) *big.Float {
+	divisor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(divisor))
+}
+
+// IsHexAddress проверяет, что строка — адрес вида 0x + 40 шестнадцатеричных символов.
+func IsHexAddress(str string) bool {
+	if len(str) != 42 || (str[:2] != "0x" && str[:2] != "0X") {
+		return false
+	}
+	for _, c := range str[2:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
 func TrimQuotes(str string) string {
 	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
 		return str[1 : len(str)-1]
@@ -1,20 +1,80 @@
 package util
 
 import (
-	"log"
+	"context"
+	"eth_bal/pkg/log"
+	"math/rand/v2"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
-func RetryWithBackoff(attempts int, delay time.Duration, fn func() error) error {
-	var err error
-	for i := 0; i < attempts; i++ {
-		if err = fn(); err == nil {
+// RetryPolicy описывает повторы: экспоненциальная задержка от Delay до MaxDelay
+// с разбросом ±Jitter (доля задержки), не более Attempts попыток и не дольше
+// MaxElapsed в сумме. Retryable отсеивает постоянные ошибки; nil — повторять все.
+// Budget, если задан, разрешает каждый повтор и позволяет ограничить их общее
+// число между всеми вызывающими сторонами.
+type RetryPolicy struct {
+	Attempts   int
+	Delay      time.Duration
+	MaxDelay   time.Duration
+	MaxElapsed time.Duration
+	Jitter     float64
+	Retryable  func(error) bool
+	Budget     interface{ AllowRetry() bool }
+}
+
+// Do повторяет fn по политике, пока не исчерпаны попытки или время либо не
+// отменён ctx. При отмене возвращается ошибка контекста, иначе последняя ошибка fn.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	start := time.Now()
+	delay := p.Delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
 			return nil
 		}
-		log.Printf("Attempt %d failed: %v. Retrying in %s...", i+1, err, delay)
-		time.Sleep(delay)
-		// Exponential backoff
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if p.Retryable != nil && !p.Retryable(err) {
+			log.Logger.WithError(err).Debug("Error is not retryable")
+			return err
+		}
+		if attempt >= p.Attempts {
+			return err
+		}
+		wait := p.jitter(delay)
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return err
+		}
+		if p.Budget != nil && !p.Budget.AllowRetry() {
+			log.Logger.WithError(err).Warn("Retry budget exhausted, giving up")
+			return err
+		}
+		log.Logger.WithFields(logrus.Fields{
+			"attempt":  attempt,
+			"error":    err.Error(),
+			"retry_in": wait,
+		}).Warn("Attempt failed, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
 		delay *= 2
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+func (p RetryPolicy) jitter(delay time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return delay
 	}
-	return err
+	return time.Duration(float64(delay) * (1 + p.Jitter*(2*rand.Float64()-1)))
 }
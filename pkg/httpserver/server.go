@@ -3,6 +3,7 @@ package httpserver
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"time"
 )
@@ -19,6 +20,8 @@ type Server struct {
 	server          *http.Server
 	notify          chan error
 	shutdownTimeout time.Duration
+	// cancel отменяет контексты всех активных запросов при остановке.
+	cancel context.CancelFunc
 }
 
 // New -.
@@ -30,10 +33,14 @@ func New(handler http.Handler, opts ...Option) *Server {
 		Addr:         _defaultAddr,
 	}
 
+	baseCtx, cancel := context.WithCancel(context.Background())
+	httpServer.BaseContext = func(net.Listener) context.Context { return baseCtx }
+
 	s := &Server{
 		server:          httpServer,
 		notify:          make(chan error, 1),
 		shutdownTimeout: _defaultShutdownTimeout,
+		cancel:          cancel,
 	}
 
 	// Custom options
@@ -60,6 +67,8 @@ func (s *Server) Notify() <-chan error {
 
 // Shutdown -.
 func (s *Server) Shutdown() error {
+	s.cancel()
+
 	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
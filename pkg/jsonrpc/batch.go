@@ -0,0 +1,61 @@
+package jsonrpc
+
+import (
+	models "eth_bal/internal/models"
+)
+
+// ResponseError возвращает *RPCError, если ответ на запрос method содержит ошибку.
+func ResponseError(method string, response models.JSONRPCResponse) error {
+	if response.Error == nil {
+		return nil
+	}
+	return &RPCError{ID: response.ID, Method: method, Code: response.Error.Code, Message: response.Error.Message}
+}
+
+// MatchBatch сопоставляет ответы пакета с запросами по ID: узлы не обязаны
+// сохранять порядок. Возвращает успешные ответы и ошибки по ID запроса;
+// ответ с неизвестным ID игнорируется, запрос без ответа считается ошибкой.
+func MatchBatch(requests []models.JSONRPCRequest, responses []models.JSONRPCResponse) (map[int64]models.JSONRPCResponse, map[int64]error) {
+	methods := make(map[int64]string, len(requests))
+	for _, request := range requests {
+		methods[request.ID] = request.Method
+	}
+	results := make(map[int64]models.JSONRPCResponse, len(responses))
+	errs := make(map[int64]error)
+	for _, response := range responses {
+		method, ok := methods[response.ID]
+		if !ok {
+			continue
+		}
+		if err := ResponseError(method, response); err != nil {
+			errs[response.ID] = err
+			continue
+		}
+		results[response.ID] = response
+	}
+	for id, method := range methods {
+		if _, ok := results[id]; ok {
+			continue
+		}
+		if _, ok := errs[id]; !ok {
+			errs[id] = &RPCError{ID: id, Method: method, Message: "no response in batch"}
+		}
+	}
+	return results, errs
+}
+
+// MatchItems — MatchBatch для ответа, разобранного потоково через SendBatchStream.
+func MatchItems(requests []models.JSONRPCRequest, items []BatchItem) (map[int64]BatchItem, map[int64]error) {
+	responses := make([]models.JSONRPCResponse, len(items))
+	byID := make(map[int64]BatchItem, len(items))
+	for i, item := range items {
+		responses[i] = models.JSONRPCResponse{ID: item.ID, Error: item.Error}
+		byID[item.ID] = item
+	}
+	matched, errs := MatchBatch(requests, responses)
+	results := make(map[int64]BatchItem, len(matched))
+	for id := range matched {
+		results[id] = byID[id]
+	}
+	return results, errs
+}
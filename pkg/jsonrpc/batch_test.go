@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"errors"
+	"eth_bal/internal/models"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestMatchBatch(t *testing.T) {
+	requests := []models.JSONRPCRequest{
+		{ID: 1, Method: "eth_getBlockByNumber"},
+		{ID: 2, Method: "eth_getBlockByNumber"},
+		{ID: 3, Method: "eth_getLogs"},
+	}
+	tests := []struct {
+		name      string
+		responses []models.JSONRPCResponse
+		results   []int64
+		errs      map[int64]int // ID -> код ошибки, 0 — ответа нет
+	}{
+		{
+			name: "responses out of order",
+			responses: []models.JSONRPCResponse{
+				{ID: 3, Result: []byte(`[]`)},
+				{ID: 1, Result: []byte(`{}`)},
+				{ID: 2, Result: []byte(`{}`)},
+			},
+			results: []int64{1, 2, 3},
+			errs:    map[int64]int{},
+		},
+		{
+			name: "error and missing response",
+			responses: []models.JSONRPCResponse{
+				{ID: 1, Result: []byte(`{}`)},
+				{ID: 3, Error: &models.RPCError{Code: -32005, Message: "query returned more than 10000 results"}},
+			},
+			results: []int64{1},
+			errs:    map[int64]int{2: 0, 3: -32005},
+		},
+		{
+			name: "unknown id ignored",
+			responses: []models.JSONRPCResponse{
+				{ID: 1, Result: []byte(`{}`)},
+				{ID: 2, Result: []byte(`{}`)},
+				{ID: 3, Result: []byte(`[]`)},
+				{ID: 42, Result: []byte(`{}`)},
+			},
+			results: []int64{1, 2, 3},
+			errs:    map[int64]int{},
+		},
+		{
+			name:    "empty response",
+			results: []int64{},
+			errs:    map[int64]int{1: 0, 2: 0, 3: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, errs := MatchBatch(requests, tt.responses)
+			got := make([]int64, 0, len(results))
+			for id := range results {
+				got = append(got, id)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			if !slices.Equal(got, tt.results) {
+				t.Errorf("results = %v, want %v", got, tt.results)
+			}
+			if len(errs) != len(tt.errs) {
+				t.Errorf("errs = %v, want %d errors", errs, len(tt.errs))
+			}
+			for id, code := range tt.errs {
+				var rpcErr *RPCError
+				if !errors.As(errs[id], &rpcErr) {
+					t.Errorf("id %d: error %v, want *RPCError", id, errs[id])
+					continue
+				}
+				if rpcErr.Code != code || rpcErr.Method != requests[id-1].Method {
+					t.Errorf("id %d: %s code %d, want %s code %d", id, rpcErr.Method, rpcErr.Code, requests[id-1].Method, code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package jsonrpc
+
+import (
+	"eth_bal/pkg/log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// BreakerState — состояние предохранителя эндпоинта.
+type BreakerState string
+
+const (
+	// BreakerClosed — запросы идут как обычно.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen — эндпоинт исключён из работы до истечения openTimeout.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen — пропускается один пробный запрос, его исход решает судьбу эндпоинта.
+	BreakerHalfOpen BreakerState = "half-open"
+
+	_defaultBreakerThreshold   = 5
+	_defaultBreakerOpenTimeout = 30 * time.Second
+)
+
+var (
+	breakerStateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "eth_bal_rpc_breaker_state",
+		Help: "Circuit breaker state per provider: 0 closed, 1 half-open, 2 open.",
+	}, []string{"provider"})
+	breakerTrips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eth_bal_rpc_breaker_trips_total",
+		Help: "Times the provider circuit breaker opened.",
+	}, []string{"provider"})
+)
+
+// breaker размыкается после threshold ошибок подряд и через openTimeout
+// пропускает один пробный запрос.
+type breaker struct {
+	provider    string
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probe    uint64 // билет текущего пробного запроса, 0 — проба не идёт
+	probes   uint64
+	trips    uint64
+}
+
+func newBreaker(provider string, threshold int, openTimeout time.Duration) *breaker {
+	b := &breaker{provider: provider, threshold: threshold, openTimeout: openTimeout, state: BreakerClosed}
+	breakerStateGauge.WithLabelValues(provider).Set(0)
+	return b
+}
+
+// allow решает, можно ли отправить запрос. В полуоткрытом состоянии
+// разрешение получает только один запрос, ему выдаётся ненулевой билет
+// пробы; у обычных запросов билет нулевой.
+func (b *breaker) allow(now time.Time) (probe uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.openTimeout {
+			return 0, false
+		}
+		b.transition(BreakerHalfOpen)
+		return b.startProbe(), true
+	case BreakerHalfOpen:
+		if b.probe != 0 {
+			return 0, false
+		}
+		return b.startProbe(), true
+	}
+	return 0, true
+}
+
+func (b *breaker) startProbe() uint64 {
+	b.probes++
+	b.probe = b.probes
+	return b.probe
+}
+
+// release возвращает разрешение пробы, если запрос не был отправлен или
+// отменён ctx. Билет чужой или устаревшей пробы ничего не меняет.
+func (b *breaker) release(probe uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if probe != 0 && probe == b.probe {
+		b.probe = 0
+	}
+}
+
+// record учитывает исход запроса с билетом probe. Пока предохранитель не
+// замкнут, решает только исход текущей пробы: ответы запросов, отправленных
+// до размыкания, его состояние не меняют.
+func (b *breaker) record(failed bool, now time.Time, probe uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerClosed {
+		if probe == 0 || probe != b.probe {
+			return
+		}
+		b.probe = 0
+	}
+	if !failed {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = now
+		b.trips++
+		breakerTrips.WithLabelValues(b.provider).Inc()
+		b.transition(BreakerOpen)
+	}
+}
+
+func (b *breaker) transition(state BreakerState) {
+	log.Logger.WithFields(logrus.Fields{
+		"provider": b.provider,
+		"from":     b.state,
+		"to":       state,
+	}).Warn("RPC circuit breaker state changed")
+	b.state = state
+	value := 0.0
+	switch state {
+	case BreakerHalfOpen:
+		value = 1
+	case BreakerOpen:
+		value = 2
+	}
+	breakerStateGauge.WithLabelValues(b.provider).Set(value)
+}
+
+// snapshot возвращает состояние с учётом истёкшего openTimeout, не меняя его.
+func (b *breaker) snapshot(now time.Time) (BreakerState, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.openTimeout {
+		return BreakerHalfOpen, b.trips
+	}
+	return b.state, b.trips
+}
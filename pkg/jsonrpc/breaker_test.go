@@ -0,0 +1,108 @@
+package jsonrpc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	b := newBreaker("test-breaker", 2, time.Minute)
+
+	assertState := func(now time.Time, want BreakerState, wantTrips uint64) {
+		t.Helper()
+		if state, trips := b.snapshot(now); state != want || trips != wantTrips {
+			t.Fatalf("state %s (%d trips), want %s (%d trips)", state, trips, want, wantTrips)
+		}
+	}
+
+	// Ошибка, сброшенная успехом, не размыкает предохранитель.
+	b.record(true, start, 0)
+	b.record(false, start, 0)
+	b.record(true, start, 0)
+	assertState(start, BreakerClosed, 0)
+
+	b.record(true, start, 0)
+	assertState(start, BreakerOpen, 1)
+	if _, ok := b.allow(start.Add(30 * time.Second)); ok {
+		t.Fatal("open breaker allowed a request before openTimeout")
+	}
+
+	// После openTimeout проходит ровно один пробный запрос.
+	now := start.Add(time.Minute)
+	assertState(now, BreakerHalfOpen, 1)
+	probe, ok := b.allow(now)
+	if !ok || probe == 0 {
+		t.Fatal("half-open breaker rejected the probe")
+	}
+	if _, ok := b.allow(now); ok {
+		t.Fatal("half-open breaker allowed a second probe")
+	}
+
+	// Неудачная проба снова размыкает предохранитель.
+	b.record(true, now, probe)
+	assertState(now, BreakerOpen, 2)
+
+	// Отменённая проба возвращает разрешение, удачная — замыкает предохранитель.
+	now = now.Add(time.Minute)
+	cancelled, ok := b.allow(now)
+	if !ok {
+		t.Fatal("half-open breaker rejected the probe")
+	}
+	b.release(cancelled)
+	probe, ok = b.allow(now)
+	if !ok {
+		t.Fatal("released probe was not allowed again")
+	}
+	// Повторное освобождение старого билета не открывает путь второй пробе.
+	b.release(cancelled)
+	if _, ok := b.allow(now); ok {
+		t.Fatal("stale release allowed a second probe")
+	}
+	// Ответ запроса, отправленного до размыкания, не решает исход пробы.
+	b.record(false, now, 0)
+	assertState(now, BreakerHalfOpen, 2)
+	b.record(false, now, probe)
+	assertState(now, BreakerClosed, 2)
+	if _, ok := b.allow(now); !ok {
+		t.Fatal("closed breaker rejected a request")
+	}
+}
+
+func TestEndpointFailureClassification(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		failed bool
+	}{
+		{"transport", &TransportError{Provider: "p", Err: errors.New("connection refused")}, true},
+		{"decode", &DecodeError{Provider: "p", Err: errors.New("unexpected EOF")}, true},
+		{"server error", &HTTPStatusError{Provider: "p", StatusCode: http.StatusBadGateway}, true},
+		{"request timeout", &HTTPStatusError{Provider: "p", StatusCode: http.StatusRequestTimeout}, true},
+		{"bad request", &HTTPStatusError{Provider: "p", StatusCode: http.StatusBadRequest}, false},
+		{"unauthorized", &HTTPStatusError{Provider: "p", StatusCode: http.StatusUnauthorized}, false},
+		{"rate limited", &HTTPStatusError{Provider: "p", StatusCode: http.StatusTooManyRequests}, false},
+		{"rpc error", &RPCError{Method: "eth_call", Code: -32000, Message: "execution reverted"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := endpointFailure(tt.err); got != tt.failed {
+				t.Fatalf("endpointFailure(%v) = %v, want %v", tt.err, got, tt.failed)
+			}
+			// Подряд больше порога таких ошибок: предохранитель размыкают только сбои эндпоинта.
+			e := newEndpoint(&Provider{name: "classify-" + tt.name}, newBreaker("classify-"+tt.name, 2, time.Minute))
+			for i := 0; i < 3; i++ {
+				e.record(time.Millisecond, tt.err, 0)
+			}
+			want := BreakerClosed
+			if tt.failed {
+				want = BreakerOpen
+			}
+			if state, _ := e.breaker.snapshot(time.Now()); state != want {
+				t.Errorf("breaker %s after three %s errors, want %s", state, tt.name, want)
+			}
+		})
+	}
+}
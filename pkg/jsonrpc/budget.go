@@ -0,0 +1,89 @@
+package jsonrpc
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	retriesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "eth_bal_rpc_retries_total",
+		Help: "Retries allowed by the retry budget.",
+	})
+	retryBudgetExhausted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "eth_bal_rpc_retry_budget_exhausted_total",
+		Help: "Retries refused because the retry budget was exhausted.",
+	})
+	retryBudgetTokens = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "eth_bal_rpc_retry_budget_tokens",
+		Help: "Retries currently available in the retry budget.",
+	})
+)
+
+// RetryBudgetStats — состояние бюджета повторов для /healthz.
+type RetryBudgetStats struct {
+	Ratio     float64 `json:"ratio"`
+	Available float64 `json:"available"`
+	Requests  uint64  `json:"requests"`
+	Retries   uint64  `json:"retries"`
+	Exhausted uint64  `json:"exhausted"`
+}
+
+// retryBudget ограничивает повторы долей ratio от числа запросов: каждый запрос
+// добавляет ratio повтора, каждый повтор тратит один. Запас не превышает reserve,
+// чтобы накопленный за спокойное время бюджет не обрушил провайдера при сбое.
+type retryBudget struct {
+	mu        sync.Mutex
+	ratio     float64
+	reserve   float64
+	tokens    float64
+	requests  uint64
+	retries   uint64
+	exhausted uint64
+}
+
+func newRetryBudget(ratio float64, reserve int) *retryBudget {
+	b := &retryBudget{ratio: ratio, reserve: float64(reserve), tokens: float64(reserve)}
+	retryBudgetTokens.Set(b.tokens)
+	return b
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	b.tokens = min(b.reserve, b.tokens+b.ratio)
+	retryBudgetTokens.Set(b.tokens)
+}
+
+// withdraw тратит один повтор из бюджета. ratio 0 отключает ограничение.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ratio > 0 && b.tokens < 1 {
+		b.exhausted++
+		retryBudgetExhausted.Inc()
+		return false
+	}
+	if b.ratio > 0 {
+		b.tokens--
+	}
+	b.retries++
+	retriesTotal.Inc()
+	retryBudgetTokens.Set(b.tokens)
+	return true
+}
+
+func (b *retryBudget) stats() RetryBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RetryBudgetStats{
+		Ratio:     b.ratio,
+		Available: b.tokens,
+		Requests:  b.requests,
+		Retries:   b.retries,
+		Exhausted: b.exhausted,
+	}
+}
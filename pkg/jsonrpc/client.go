@@ -2,55 +2,252 @@ package jsonrpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	models "eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"eth_bal/pkg/log"
 	"fmt"
 	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
-func SendJSONRPCRequest(client *http.Client, apiKey string, request models.JSONRPCRequest, response *models.JSONRPCResponse) error {
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return err
+const (
+	_defaultRetryAttempts = 5
+	_defaultRetryDelay    = time.Second
+)
+
+// Client отправляет запросы JSON-RPC в пул провайдеров. Запрос уходит самому
+// здоровому провайдеру, при транспортной или HTTP-ошибке — следующему.
+// У каждого провайдера свой предохранитель; повторы вызывающих сторон
+// ограничены общим бюджетом (см. AllowRetry).
+type Client struct {
+	httpClient *http.Client
+	endpoints  []*endpoint
+	retry      util.RetryPolicy
+	retries    map[string]util.RetryPolicy
+	budget     *retryBudget
+	hedges     map[string]*hedge
+
+	breakerThreshold   int
+	breakerOpenTimeout time.Duration
+	budgetRatio        float64
+	budgetReserve      int
+}
+
+// ClientOption -.
+type ClientOption func(*Client)
+
+// Retry задаёт политику повторов по умолчанию и переопределения для отдельных
+// методов JSON-RPC (см. RetryPolicy). Без опции действует _defaultRetryAttempts
+// попыток с начальной задержкой _defaultRetryDelay.
+func Retry(policy util.RetryPolicy, methods map[string]util.RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+		c.retries = methods
 	}
-	req, err := http.NewRequest("POST", "https://go.getblock.io/"+apiKey+"/", bytes.NewReader(jsonData))
-	if err != nil {
-		return err
+}
+
+// Breaker задаёт число ошибок подряд, после которого предохранитель эндпоинта
+// размыкается, и время до пробного запроса.
+func Breaker(threshold int, openTimeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.breakerThreshold = threshold
+		c.breakerOpenTimeout = openTimeout
 	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+}
+
+// RetryBudget ограничивает повторы долей ratio от числа запросов с запасом
+// reserve повторов. ratio 0 отключает ограничение.
+func RetryBudget(ratio float64, reserve int) ClientOption {
+	return func(c *Client) {
+		c.budgetRatio = ratio
+		c.budgetReserve = reserve
 	}
-	defer resp.Body.Close()
-	err = json.NewDecoder(resp.Body).Decode(response)
-	if err != nil {
+}
+
+func NewClient(httpClient *http.Client, providers []*Provider, opts ...ClientOption) (*Client, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("at least one provider is required")
+	}
+	c := &Client{
+		httpClient:         httpClient,
+		retry:              util.RetryPolicy{Attempts: _defaultRetryAttempts, Delay: _defaultRetryDelay},
+		breakerThreshold:   _defaultBreakerThreshold,
+		breakerOpenTimeout: _defaultBreakerOpenTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for method, h := range c.hedges {
+		if h.percentile < 0 || h.percentile > 1 {
+			return nil, fmt.Errorf("hedge percentile %v for %s is outside [0, 1]", h.percentile, method)
+		}
+	}
+	c.budget = newRetryBudget(c.budgetRatio, c.budgetReserve)
+	for _, provider := range providers {
+		c.endpoints = append(c.endpoints, newEndpoint(provider, newBreaker(provider.Name(), c.breakerThreshold, c.breakerOpenTimeout)))
+	}
+	return c, nil
+}
+
+// Health возвращает состояние провайдеров в порядке их текущего приоритета.
+func (c *Client) Health() []ProviderHealth {
+	now := time.Now()
+	endpoints := ranked(c.endpoints)
+	health := make([]ProviderHealth, len(endpoints))
+	for i, e := range endpoints {
+		health[i] = e.health(now)
+	}
+	return health
+}
+
+// RetryPolicy возвращает политику повторов для метода JSON-RPC. Повторяются
+// только ошибки, которые Retryable считает временными, и только пока
+// не исчерпан бюджет повторов клиента.
+func (c *Client) RetryPolicy(method string) util.RetryPolicy {
+	policy, ok := c.retries[method]
+	if !ok {
+		policy = c.retry
+	}
+	policy.Retryable = Retryable
+	policy.Budget = c
+	return policy
+}
+
+// RetryBudget возвращает состояние бюджета повторов.
+func (c *Client) RetryBudget() RetryBudgetStats {
+	return c.budget.stats()
+}
+
+// AllowRetry списывает один повтор из бюджета; false — бюджет исчерпан и
+// повторять не следует.
+func (c *Client) AllowRetry() bool {
+	return c.budget.withdraw()
+}
+
+func (c *Client) SendJSONRPCRequest(ctx context.Context, request models.JSONRPCRequest, response *models.JSONRPCResponse) error {
+	if err := c.post(ctx, request, response); err != nil {
 		return err
 	}
 	if response.Error != nil {
-		return fmt.Errorf("JSON-RPC Error: %s", response.Error.Message)
+		return &RPCError{ID: request.ID, Method: request.Method, Code: response.Error.Code, Message: response.Error.Message}
 	}
 	return nil
 }
 
-func SendBatchJSONRPCRequest(client *http.Client, apiKey string, requests []models.JSONRPCRequest, responses *[]models.JSONRPCResponse) error {
-	jsonData, err := json.Marshal(requests)
+func (c *Client) SendBatchJSONRPCRequest(ctx context.Context, requests []models.JSONRPCRequest, responses *[]models.JSONRPCResponse) error {
+	return c.post(ctx, requests, responses)
+}
+
+func (c *Client) post(ctx context.Context, body any, out any) error {
+	jsonData, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", "https://go.getblock.io/"+apiKey+"/", bytes.NewReader(jsonData))
+	c.budget.deposit()
+	endpoints := ranked(c.endpoints)
+	if h := c.hedges[methodOf(body)]; h != nil {
+		return c.hedged(ctx, h, endpoints, body, jsonData, out)
+	}
+	return c.failover(ctx, endpoints, body, jsonData, out)
+}
+
+// failover отправляет запрос эндпоинтам по очереди до первого успешного ответа.
+func (c *Client) failover(ctx context.Context, endpoints []*endpoint, body any, jsonData []byte, out any) error {
+	var lastErr error
+	for _, e := range endpoints {
+		probe, ok := e.breaker.allow(time.Now())
+		if !ok {
+			continue
+		}
+		if err := c.throttle(ctx, e.provider, body); err != nil {
+			e.breaker.release(probe)
+			return err
+		}
+		start := time.Now()
+		err := c.postTo(ctx, e.provider, jsonData, out)
+		if ctx.Err() != nil {
+			// Отмена вызывающей стороной не говорит о здоровье провайдера.
+			e.breaker.release(probe)
+			if errors.Is(context.Cause(ctx), errHedgeLost) {
+				e.observe(time.Since(start))
+			}
+			return ctx.Err()
+		}
+		e.record(time.Since(start), err, probe)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if len(c.endpoints) > 1 {
+			log.Logger.WithFields(logrus.Fields{
+				"provider": e.provider.Name(),
+				"error":    err.Error(),
+			}).Warn("RPC provider failed, failing over")
+		}
+	}
+	if lastErr == nil {
+		return ErrCircuitOpen
+	}
+	return lastErr
+}
+
+// throttle ждёт разрешения лимитера провайдера на отправку body.
+func (c *Client) throttle(ctx context.Context, provider *Provider, body any) error {
+	cost := provider.limiter.cost(body)
+	waited, err := provider.limiter.wait(ctx, cost)
+	if waited > 0 {
+		throttledRequests.WithLabelValues(provider.Name()).Inc()
+		throttleWait.WithLabelValues(provider.Name()).Add(waited.Seconds())
+		log.Logger.WithFields(logrus.Fields{
+			"provider": provider.Name(),
+			"cost":     cost,
+			"wait":     waited,
+		}).Debug("Request throttled by rate limiter")
+	}
+	return err
+}
+
+func (c *Client) postTo(ctx context.Context, provider *Provider, jsonData []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", provider.endpoint(provider.apiKey), bytes.NewReader(jsonData))
 	if err != nil {
-		return err
+		return provider.redact(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	provider.apply(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return &TransportError{Provider: provider.Name(), Err: provider.redact(err)}
 	}
 	defer resp.Body.Close()
-	err = json.NewDecoder(resp.Body).Decode(responses)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		pause := retryAfter(resp.Header)
+		provider.limiter.pause(pause)
+		rateLimited.WithLabelValues(provider.Name()).Inc()
+		log.Logger.WithFields(logrus.Fields{
+			"provider":    provider.Name(),
+			"retry_after": pause,
+		}).Warn("RPC provider rate limited the request")
+		return &HTTPStatusError{Provider: provider.Name(), StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: pause}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPStatusError{Provider: provider.Name(), StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	dec := json.NewDecoder(resp.Body)
+	if results, ok := out.(*BatchResults); ok {
+		err = results.decode(dec)
+	} else {
+		err = dec.Decode(out)
+	}
 	if err != nil {
-		return err
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &DecodeError{Provider: provider.Name(), Err: err}
 	}
 	return nil
 }
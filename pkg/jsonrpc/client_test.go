@@ -0,0 +1,95 @@
+package jsonrpc
+
+import (
+	"context"
+	"eth_bal/internal/models"
+	"eth_bal/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientRetryPolicy(t *testing.T) {
+	provider, err := NewProvider("http://localhost", Auth(AuthNone, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := NewClient(nil, []*Provider{provider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy := defaults.RetryPolicy("eth_getLogs"); policy.Attempts != _defaultRetryAttempts || policy.Delay != _defaultRetryDelay {
+		t.Errorf("default policy = %d attempts, %s delay", policy.Attempts, policy.Delay)
+	}
+
+	client, err := NewClient(nil, []*Provider{provider}, Retry(
+		util.RetryPolicy{Attempts: 3, Delay: time.Second},
+		map[string]util.RetryPolicy{"eth_getLogs": {Attempts: 8, Delay: 2 * time.Second}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method   string
+		attempts int
+		delay    time.Duration
+	}{
+		{"eth_getLogs", 8, 2 * time.Second},
+		{"eth_blockNumber", 3, time.Second},
+	}
+	for _, tt := range tests {
+		policy := client.RetryPolicy(tt.method)
+		if policy.Attempts != tt.attempts || policy.Delay != tt.delay {
+			t.Errorf("%s: %d attempts, %s delay, want %d, %s", tt.method, policy.Attempts, policy.Delay, tt.attempts, tt.delay)
+		}
+		// Классификация ошибок и бюджет повторов берутся из клиента.
+		if policy.Retryable == nil || policy.Budget != client {
+			t.Errorf("%s: policy without Retryable or client budget", tt.method)
+		}
+	}
+}
+
+// TestClientBreakerIgnoresRequestErrors: отказ в запросе и ошибка узла не
+// размыкают предохранитель даже при пороге в одну ошибку, а 5xx — размыкает.
+func TestClientBreakerIgnoresRequestErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		want  BreakerState
+	}{
+		{"bad request", func(w http.ResponseWriter) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}, BreakerClosed},
+		{"rpc error", func(w http.ResponseWriter) {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"execution reverted"}}`))
+		}, BreakerClosed},
+		{"server error", func(w http.ResponseWriter) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}, BreakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.write(w)
+			}))
+			defer server.Close()
+			provider, err := NewProvider(server.URL, Name("breaker-"+tt.name), Auth(AuthNone, "", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := NewClient(server.Client(), []*Provider{provider}, Breaker(1, time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			request := models.JSONRPCRequest{JSONRPC: "2.0", Method: "eth_call", ID: 1}
+			var response models.JSONRPCResponse
+			if err := client.SendJSONRPCRequest(context.Background(), request, &response); err == nil {
+				t.Fatal("request succeeded, want an error")
+			}
+			if got := client.Health()[0].Breaker; got != tt.want {
+				t.Errorf("breaker %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
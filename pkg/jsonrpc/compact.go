@@ -0,0 +1,158 @@
+package jsonrpc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Address — 20-байтовый адрес, разобранный из 0x-строки.
+type Address [20]byte
+
+// Hash — 32-байтовый хэш, разобранный из 0x-строки.
+type Hash [32]byte
+
+// Uint64 — число-quantity JSON-RPC (0x-строка), умещающееся в uint64.
+type Uint64 uint64
+
+// Quantity — число-quantity JSON-RPC произвольной величины (суммы в wei).
+type Quantity struct {
+	big.Int
+}
+
+// CompactTx — транзакция без полей, не нужных для учёта балансов
+// (input, подписи, access list). To равен nil при создании контракта.
+type CompactTx struct {
+	Hash             Hash     `json:"hash"`
+	From             Address  `json:"from"`
+	To               *Address `json:"to"`
+	Value            Quantity `json:"value"`
+	Gas              Uint64   `json:"gas"`
+	GasPrice         Quantity `json:"gasPrice"`
+	TransactionIndex Uint64   `json:"transactionIndex"`
+}
+
+// CompactWithdrawal — вывод с Beacon Chain; Amount в gwei.
+type CompactWithdrawal struct {
+	Index          Uint64  `json:"index"`
+	ValidatorIndex Uint64  `json:"validatorIndex"`
+	Address        Address `json:"address"`
+	Amount         Uint64  `json:"amount"`
+}
+
+// CompactBlock — блок с полными транзакциями (fullTx=true) в компактном виде.
+// BaseFeePerGas равен nil до London.
+type CompactBlock struct {
+	Number        Uint64              `json:"number"`
+	Hash          Hash                `json:"hash"`
+	ParentHash    Hash                `json:"parentHash"`
+	Miner         Address             `json:"miner"`
+	BaseFeePerGas *Quantity           `json:"baseFeePerGas"`
+	GasUsed       Uint64              `json:"gasUsed"`
+	Transactions  []CompactTx         `json:"transactions"`
+	Withdrawals   []CompactWithdrawal `json:"withdrawals"`
+}
+
+func (a *Address) UnmarshalJSON(data []byte) error {
+	return decodeFixedHex(data, a[:])
+}
+
+func (a Address) Hex() string {
+	return "0x" + hex.EncodeToString(a[:])
+}
+
+func (h *Hash) UnmarshalJSON(data []byte) error {
+	return decodeFixedHex(data, h[:])
+}
+
+func (h Hash) Hex() string {
+	return "0x" + hex.EncodeToString(h[:])
+}
+
+func (n *Uint64) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	digits, err := hexDigits(data)
+	if err != nil {
+		return err
+	}
+	v, ok := parseHexUint64(digits)
+	if !ok {
+		return fmt.Errorf("invalid quantity %s", data)
+	}
+	*n = Uint64(v)
+	return nil
+}
+
+func (n Uint64) Hex() string {
+	return "0x" + strconv.FormatUint(uint64(n), 16)
+}
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	digits, err := hexDigits(data)
+	if err != nil {
+		return err
+	}
+	// Большинство сумм умещается в uint64 — разбираем их без выделения памяти.
+	if v, ok := parseHexUint64(digits); ok {
+		q.Int.SetUint64(v)
+		return nil
+	}
+	if _, ok := q.Int.SetString(string(digits), 16); !ok {
+		return fmt.Errorf("invalid quantity %s", data)
+	}
+	return nil
+}
+
+func (q *Quantity) Hex() string {
+	return "0x" + q.Int.Text(16)
+}
+
+// hexDigits возвращает цифры JSON-строки "0x…" без кавычек и префикса.
+func hexDigits(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != '"' || data[len(data)-1] != '"' || data[1] != '0' || (data[2] != 'x' && data[2] != 'X') {
+		return nil, fmt.Errorf("invalid hex value %s", data)
+	}
+	return data[3 : len(data)-1], nil
+}
+
+func parseHexUint64(digits []byte) (uint64, bool) {
+	if len(digits) == 0 || len(digits) > 16 {
+		return 0, false
+	}
+	var v uint64
+	for _, c := range digits {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, false
+		}
+		v = v<<4 | uint64(c)
+	}
+	return v, true
+}
+
+func decodeFixedHex(data []byte, dst []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	digits, err := hexDigits(data)
+	if err != nil {
+		return err
+	}
+	if len(digits) != 2*len(dst) {
+		return fmt.Errorf("invalid hex value %s: want %d bytes", data, len(dst))
+	}
+	_, err = hex.Decode(dst, digits)
+	return err
+}
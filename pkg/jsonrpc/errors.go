@@ -0,0 +1,114 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Стандартные коды ошибок JSON-RPC 2.0, на которые повтор не поможет.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+)
+
+// ErrCircuitOpen возвращается, если предохранители всех провайдеров разомкнуты.
+// Повторять запрос до пробного окна бессмысленно.
+var ErrCircuitOpen = errors.New("all RPC providers are unavailable: circuit breakers open")
+
+// TransportError — запрос не дошёл до провайдера или ответ не был получен.
+type TransportError struct {
+	Provider string
+	Err      error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s: transport: %v", e.Provider, e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// Retryable: сетевые сбои и таймауты HTTP-клиента временные. Отмену контекста
+// вызывающей стороны проверяет цикл повторов.
+func (e *TransportError) Retryable() bool { return true }
+
+// HTTPStatusError — провайдер ответил статусом, отличным от 200.
+type HTTPStatusError struct {
+	Provider   string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return fmt.Sprintf("%s: rate limited, retry after %s", e.Provider, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s: unexpected HTTP status %s", e.Provider, e.Status)
+}
+
+// Retryable: 408, 429 и 5xx — временные; 401, 403 и прочие 4xx — нет
+// (неверный ключ или запрос не исправятся повтором).
+func (e *HTTPStatusError) Retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// RPCError — ответ узла с полем error, либо отсутствующий ответ в пакете (Code 0).
+type RPCError struct {
+	ID      int64
+	Method  string
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("%s (id %d): %s", e.Method, e.ID, e.Message)
+	}
+	return fmt.Sprintf("%s (id %d): JSON-RPC error %d: %s", e.Method, e.ID, e.Code, e.Message)
+}
+
+// Retryable: ошибки формата запроса и неизвестного метода постоянны,
+// серверные (-32000..-32099, -32603) обычно временные.
+func (e *RPCError) Retryable() bool {
+	switch e.Code {
+	case CodeParseError, CodeInvalidRequest, CodeMethodNotFound, CodeInvalidParams:
+		return false
+	}
+	return true
+}
+
+// DecodeError — тело ответа не удалось разобрать как JSON-RPC.
+type DecodeError struct {
+	Provider string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: decode response: %v", e.Provider, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Retryable: обрезанный или испорченный ответ чаще всего случаен.
+func (e *DecodeError) Retryable() bool { return true }
+
+// Retryable сообщает, имеет ли смысл повторять запрос после err. Ошибки, не
+// описанные типами этого пакета, считаются временными; отмена контекста и
+// ErrCircuitOpen — нет.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,189 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	models "eth_bal/internal/models"
+	"eth_bal/pkg/log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// _hedgeWindow — сколько последних задержек учитывается в перцентиле.
+	_hedgeWindow = 256
+	// _minHedgeSamples — до стольких наблюдений запросы не дублируются.
+	_minHedgeSamples = 20
+)
+
+// errHedgeLost — причина отмены запроса, проигравшего гонку с дублем.
+var errHedgeLost = errors.New("hedged request lost the race")
+
+var hedgeOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "eth_bal_rpc_hedge_requests_total",
+	Help: "Hedged requests by outcome: win — the hedge answered first, loss — the original did, failed — neither succeeded.",
+}, []string{"method", "outcome"})
+
+// hedge хранит скользящее окно задержек метода и выбирает момент дублирования запроса.
+type hedge struct {
+	method     string
+	percentile float64
+	minDelay   time.Duration
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// Hedge включает дублирование запросов методов methods: если ответ не пришёл
+// за percentile (0..1) наблюдённых задержек, но не раньше minDelay, тот же запрос
+// уходит следующему эндпоинту и побеждает первый успешный ответ. Пакет
+// относится к методу своего первого запроса.
+func Hedge(percentile float64, minDelay time.Duration, methods ...string) ClientOption {
+	return func(c *Client) {
+		if c.hedges == nil {
+			c.hedges = make(map[string]*hedge)
+		}
+		for _, method := range methods {
+			c.hedges[method] = &hedge{method: method, percentile: percentile, minDelay: minDelay}
+		}
+	}
+}
+
+func (h *hedge) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) < _hedgeWindow {
+		h.samples = append(h.samples, d)
+		return
+	}
+	h.samples[h.next] = d
+	h.next = (h.next + 1) % _hedgeWindow
+}
+
+// delay возвращает задержку перед дублированием; false — наблюдений пока мало.
+func (h *hedge) delay() (time.Duration, bool) {
+	h.mu.Lock()
+	if len(h.samples) < _minHedgeSamples {
+		h.mu.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), h.samples...)
+	h.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	d := sorted[int(h.percentile*float64(len(sorted)-1))]
+	return max(d, h.minDelay), true
+}
+
+func methodOf(body any) string {
+	switch body := body.(type) {
+	case models.JSONRPCRequest:
+		return body.Method
+	case []models.JSONRPCRequest:
+		if len(body) > 0 {
+			return body[0].Method
+		}
+	}
+	return ""
+}
+
+type hedgeResult struct {
+	out    any
+	err    error
+	hedged bool
+}
+
+// hedged отправляет запрос через failover и, если ответа нет дольше h.delay,
+// дублирует его, начиная со следующего по рейтингу эндпоинта (с единственным
+// эндпоинтом — по новому соединению). Проигравший запрос отменяется; его
+// отмена не считается ошибкой эндпоинта, но затраченное время учитывается в его
+// задержке, чтобы медленный эндпоинт опустился в рейтинге. Дубли расходуют
+// бюджет повторов.
+func (c *Client) hedged(ctx context.Context, h *hedge, endpoints []*endpoint, body any, jsonData []byte, out any) error {
+	start := time.Now()
+	delay, ok := h.delay()
+	if !ok {
+		err := c.failover(ctx, endpoints, body, jsonData, out)
+		if err == nil {
+			h.observe(time.Since(start))
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(errHedgeLost)
+	results := make(chan hedgeResult, 2)
+	launch := func(endpoints []*endpoint, hedged bool) {
+		target := newTarget(out)
+		go func() {
+			err := c.failover(ctx, endpoints, body, jsonData, target)
+			results <- hedgeResult{out: target, err: err, hedged: hedged}
+		}()
+	}
+	launch(endpoints, false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	inflight, hedgeSent := 1, false
+	var firstErr error
+	for inflight > 0 {
+		select {
+		case <-timer.C:
+			if hedgeSent || !c.budget.withdraw() {
+				continue
+			}
+			hedgeSent = true
+			inflight++
+			rotated := append(append([]*endpoint(nil), endpoints[1:]...), endpoints[0])
+			log.Logger.WithFields(logrus.Fields{
+				"method": h.method,
+				"delay":  delay,
+			}).Debug("Request is slow, sending hedge")
+			launch(rotated, true)
+		case result := <-results:
+			inflight--
+			if result.err != nil {
+				if firstErr == nil {
+					firstErr = result.err
+				}
+				if inflight == 0 && !hedgeSent {
+					// Исходный запрос прошёл все эндпоинты до срабатывания таймера.
+					return result.err
+				}
+				continue
+			}
+			cancel(errHedgeLost)
+			reflect.ValueOf(out).Elem().Set(reflect.ValueOf(result.out).Elem())
+			h.observe(time.Since(start))
+			if hedgeSent {
+				outcome := "loss"
+				if result.hedged {
+					outcome = "win"
+				}
+				hedgeOutcomes.WithLabelValues(h.method, outcome).Inc()
+			}
+			return nil
+		}
+	}
+	if hedgeSent {
+		hedgeOutcomes.WithLabelValues(h.method, "failed").Inc()
+	}
+	if err := ctx.Err(); err != nil && firstErr == nil {
+		return err
+	}
+	return firstErr
+}
+
+// newTarget создаёт пустой приёмник того же вида, что out, для второго запроса.
+func newTarget(out any) any {
+	if results, ok := out.(*BatchResults); ok {
+		return NewBatchResults(results.newResult)
+	}
+	return reflect.New(reflect.TypeOf(out).Elem()).Interface()
+}
@@ -0,0 +1,288 @@
+package jsonrpc
+
+import (
+	"context"
+	"eth_bal/internal/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	_hedgeTestMethod = "eth_hedgeTest"
+	_hedgeTestDelay  = 20 * time.Millisecond
+	_slowAnswer      = 300 * time.Millisecond
+)
+
+// hedgeServer — эндпоинт, который отвечает своим именем через delay
+// или статусом status и запоминает запросы, отменённые клиентом.
+type hedgeServer struct {
+	*httptest.Server
+	name string
+
+	mu        sync.Mutex
+	delay     time.Duration
+	status    int
+	requests  int
+	cancelled chan struct{}
+}
+
+func newHedgeServer(t *testing.T, name string) *hedgeServer {
+	s := &hedgeServer{name: name, status: http.StatusOK, cancelled: make(chan struct{}, 8)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Пока тело не прочитано, сервер не замечает закрытия соединения клиентом.
+		_, _ = io.Copy(io.Discard, r.Body)
+		s.mu.Lock()
+		s.requests++
+		delay, status := s.delay, s.status
+		s.mu.Unlock()
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			s.cancelled <- struct{}{}
+			return
+		}
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"` + s.name + `"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *hedgeServer) set(delay time.Duration, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay, s.status = delay, status
+}
+
+func (s *hedgeServer) hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// newHedgeClient собирает клиента с дублированием _hedgeTestMethod: первым
+// в рейтинге стоит slow, вторым — fast.
+func newHedgeClient(t *testing.T, slow, fast *hedgeServer, opts ...ClientOption) *Client {
+	t.Helper()
+	var providers []*Provider
+	for _, s := range []*hedgeServer{slow, fast} {
+		provider, err := NewProvider(s.URL, Name(t.Name()+"-"+s.name), Auth(AuthNone, "", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		providers = append(providers, provider)
+	}
+	opts = append([]ClientOption{Hedge(0.5, _hedgeTestDelay, _hedgeTestMethod)}, opts...)
+	client, err := NewClient(&http.Client{}, providers, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// warmUp заполняет окно задержек метода так, чтобы дубль уходил через _hedgeTestDelay.
+func warmUp(c *Client) {
+	h := c.hedges[_hedgeTestMethod]
+	for i := 0; i < _minHedgeSamples; i++ {
+		h.observe(time.Millisecond)
+	}
+}
+
+// rank возвращает slow на первое место рейтинга, которое он теряет после медленных ответов.
+func rank(c *Client) {
+	for i, latency := range []time.Duration{time.Millisecond, 50 * time.Millisecond} {
+		e := c.endpoints[i]
+		e.mu.Lock()
+		e.latency, e.errorRate = latency, 0
+		e.mu.Unlock()
+	}
+}
+
+func sendHedged(c *Client) (string, error) {
+	request := models.JSONRPCRequest{JSONRPC: "2.0", Method: _hedgeTestMethod, ID: 1}
+	var response models.JSONRPCResponse
+	if err := c.SendJSONRPCRequest(context.Background(), request, &response); err != nil {
+		return "", err
+	}
+	return string(response.Result), nil
+}
+
+func hedgeOutcome(t *testing.T, outcome string) float64 {
+	t.Helper()
+	return counterValue(t, hedgeOutcomes.WithLabelValues(_hedgeTestMethod, outcome))
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestHedgeFastEndpointWins(t *testing.T) {
+	slow, fast := newHedgeServer(t, "slow"), newHedgeServer(t, "fast")
+	slow.set(_slowAnswer, http.StatusOK)
+	client := newHedgeClient(t, slow, fast)
+	warmUp(client)
+	rank(client)
+	wins, losses := hedgeOutcome(t, "win"), hedgeOutcome(t, "loss")
+
+	started := time.Now()
+	got, err := sendHedged(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `"fast"` {
+		t.Errorf("result %s, want the hedge answer", got)
+	}
+	if elapsed := time.Since(started); elapsed >= _slowAnswer {
+		t.Errorf("hedged request took %s, slow endpoint answers in %s", elapsed, _slowAnswer)
+	}
+	// Проигравший запрос отменяется, не дожидаясь ответа медленного эндпоинта.
+	select {
+	case <-slow.cancelled:
+	case <-time.After(_slowAnswer):
+		t.Error("losing request was not cancelled")
+	}
+	if d := hedgeOutcome(t, "win") - wins; d != 1 {
+		t.Errorf("win outcomes +%v, want +1", d)
+	}
+	if d := hedgeOutcome(t, "loss") - losses; d != 0 {
+		t.Errorf("loss outcomes +%v, want +0", d)
+	}
+	// Отмена проигравшего не считается ошибкой эндпоинта.
+	for _, health := range client.Health() {
+		if health.Failures != 0 || health.Breaker != BreakerClosed {
+			t.Errorf("%s: %d failures, breaker %s after a lost race", health.Name, health.Failures, health.Breaker)
+		}
+	}
+}
+
+func TestHedgeOriginalWins(t *testing.T) {
+	slow, fast := newHedgeServer(t, "slow"), newHedgeServer(t, "fast")
+	// Исходный запрос опаздывает к таймеру дубля, но отвечает раньше дубля.
+	slow.set(3*_hedgeTestDelay, http.StatusOK)
+	fast.set(_slowAnswer, http.StatusOK)
+	client := newHedgeClient(t, slow, fast)
+	warmUp(client)
+	rank(client)
+	wins, losses := hedgeOutcome(t, "win"), hedgeOutcome(t, "loss")
+
+	got, err := sendHedged(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `"slow"` {
+		t.Errorf("result %s, want the original answer", got)
+	}
+	select {
+	case <-fast.cancelled:
+	case <-time.After(_slowAnswer):
+		t.Error("losing hedge was not cancelled")
+	}
+	if d := hedgeOutcome(t, "loss") - losses; d != 1 {
+		t.Errorf("loss outcomes +%v, want +1", d)
+	}
+	if d := hedgeOutcome(t, "win") - wins; d != 0 {
+		t.Errorf("win outcomes +%v, want +0", d)
+	}
+}
+
+func TestHedgeBothFail(t *testing.T) {
+	slow, fast := newHedgeServer(t, "slow"), newHedgeServer(t, "fast")
+	// Исходный запрос падает после таймера дубля, дубль — сразу на обоих эндпоинтах.
+	slow.set(3*_hedgeTestDelay, http.StatusBadGateway)
+	fast.set(0, http.StatusBadGateway)
+	client := newHedgeClient(t, slow, fast)
+	warmUp(client)
+	rank(client)
+	failed := hedgeOutcome(t, "failed")
+
+	if _, err := sendHedged(client); err == nil {
+		t.Fatal("request succeeded, want an error")
+	}
+	if d := hedgeOutcome(t, "failed") - failed; d != 1 {
+		t.Errorf("failed outcomes +%v, want +1", d)
+	}
+}
+
+// TestHedgeWarmUp: пока наблюдений меньше _minHedgeSamples, запрос не дублируется.
+func TestHedgeWarmUp(t *testing.T) {
+	slow, fast := newHedgeServer(t, "slow"), newHedgeServer(t, "fast")
+	slow.set(3*_hedgeTestDelay, http.StatusOK)
+	client := newHedgeClient(t, slow, fast)
+	h := client.hedges[_hedgeTestMethod]
+	for i := 0; i < _minHedgeSamples-1; i++ {
+		h.observe(time.Millisecond)
+	}
+	if _, ok := h.delay(); ok {
+		t.Fatalf("hedge delay is available after %d samples", _minHedgeSamples-1)
+	}
+	rank(client)
+	got, err := sendHedged(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `"slow"` || fast.hits() != 0 {
+		t.Errorf("result %s with %d hedges during warm-up, want the original answer and no hedge", got, fast.hits())
+	}
+	if retries := client.RetryBudget().Retries; retries != 0 {
+		t.Errorf("warm-up spent %d retries", retries)
+	}
+
+	// Задержка последнего запроса стала двадцатым наблюдением: теперь дубль уходит.
+	if _, ok := h.delay(); !ok {
+		t.Fatal("hedge delay is not available after the warm-up")
+	}
+	rank(client)
+	if got, err := sendHedged(client); err != nil || got != `"fast"` {
+		t.Errorf("result %s (%v) after the warm-up, want the hedge answer", got, err)
+	}
+}
+
+func TestHedgeRetryBudget(t *testing.T) {
+	tests := []struct {
+		name          string
+		ratio         float64
+		reserve       int
+		want          string
+		wantRetries   uint64
+		wantExhausted uint64
+	}{
+		{"budget spent on hedge", 0.5, 5, `"fast"`, 1, 0},
+		{"exhausted budget blocks hedge", 0.5, 0, `"slow"`, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slow, fast := newHedgeServer(t, "slow"), newHedgeServer(t, "fast")
+			slow.set(3*_hedgeTestDelay, http.StatusOK)
+			client := newHedgeClient(t, slow, fast, RetryBudget(tt.ratio, tt.reserve))
+			warmUp(client)
+			rank(client)
+			got, err := sendHedged(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("result %s, want %s", got, tt.want)
+			}
+			stats := client.RetryBudget()
+			if stats.Retries != tt.wantRetries || stats.Exhausted != tt.wantExhausted {
+				t.Errorf("budget: %d retries, %d exhausted, want %d and %d",
+					stats.Retries, stats.Exhausted, tt.wantRetries, tt.wantExhausted)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package jsonrpc
+
+import (
+	"context"
+	models "eth_bal/internal/models"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// _defaultMethodCost — стоимость метода, не указанного в MethodCosts.
+	_defaultMethodCost = 1
+	// _defaultRetryAfter — пауза после 429 без заголовка Retry-After.
+	_defaultRetryAfter = time.Second
+)
+
+var (
+	throttledRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eth_bal_rpc_throttled_requests_total",
+		Help: "Requests delayed by the outbound rate limiter.",
+	}, []string{"provider"})
+	throttleWait = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eth_bal_rpc_throttle_wait_seconds_total",
+		Help: "Total time requests spent waiting for the outbound rate limiter.",
+	}, []string{"provider"})
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eth_bal_rpc_rate_limited_total",
+		Help: "HTTP 429 responses received from the provider.",
+	}, []string{"provider"})
+)
+
+// limiter — token bucket в единицах стоимости запроса (compute units).
+// Помимо пополнения ведра учитывает паузу, заданную провайдером через Retry-After.
+type limiter struct {
+	mu          sync.Mutex
+	rate        float64 // единиц в секунду; 0 — без ограничения
+	burst       float64
+	costs       map[string]float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+
+	throttled   uint64
+	waited      time.Duration
+	rateLimited uint64
+}
+
+func newLimiter() *limiter {
+	return &limiter{costs: make(map[string]float64)}
+}
+
+// cost возвращает стоимость тела запроса: одиночного или пакета.
+func (l *limiter) cost(body any) float64 {
+	switch body := body.(type) {
+	case models.JSONRPCRequest:
+		return l.methodCost(body.Method)
+	case []models.JSONRPCRequest:
+		var total float64
+		for _, request := range body {
+			total += l.methodCost(request.Method)
+		}
+		return total
+	}
+	return _defaultMethodCost
+}
+
+func (l *limiter) methodCost(method string) float64 {
+	if cost, ok := l.costs[method]; ok {
+		return cost
+	}
+	return _defaultMethodCost
+}
+
+// reserve списывает cost и возвращает, сколько нужно подождать до отправки.
+// Долг ведра (отрицательный остаток) сохраняется, поэтому параллельные
+// запросы выстраиваются в очередь, а не ждут одного и того же токена.
+func (l *limiter) reserve(cost float64, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	if l.rate > 0 {
+		if l.last.IsZero() {
+			l.tokens = l.burst
+		} else {
+			l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		}
+		l.last = now
+		// Пакет дороже ведра всё равно должен пройти, иначе он не пройдёт никогда.
+		l.tokens -= min(cost, l.burst)
+		if l.tokens < 0 {
+			wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+	if pause := l.pausedUntil.Sub(now); pause > wait {
+		wait = pause
+	}
+	if wait > 0 {
+		l.throttled++
+		l.waited += wait
+	}
+	return wait
+}
+
+// wait блокирует до разрешения отправить запрос стоимостью cost.
+func (l *limiter) wait(ctx context.Context, cost float64) (time.Duration, error) {
+	d := l.reserve(cost, time.Now())
+	if d <= 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return d, ctx.Err()
+	case <-timer.C:
+		return d, nil
+	}
+}
+
+// pause приостанавливает отправку на d после ответа 429.
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rateLimited++
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	l.tokens = min(l.tokens, 0)
+}
+
+func (l *limiter) stats() (throttled uint64, waited time.Duration, rateLimited uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.throttled, l.waited, l.rateLimited
+}
+
+// retryAfter разбирает Retry-After в секундах или в формате HTTP-даты.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return _defaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return _defaultRetryAfter
+}
@@ -0,0 +1,115 @@
+package jsonrpc
+
+import (
+	"eth_bal/internal/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type reserveStep struct {
+	after time.Duration // смещение от начала теста
+	cost  float64
+	want  time.Duration
+}
+
+func TestLimiterReserve(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name  string
+		steps []reserveStep
+	}{
+		{
+			name: "burst then queue",
+			steps: []reserveStep{
+				{0, 5, 0},
+				{0, 5, 0},
+				// ведро пусто: 10 единиц при 10/с — секунда
+				{0, 10, time.Second},
+				// долг сохраняется, следующий запрос встаёт в очередь за предыдущим
+				{0, 5, 1500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refill over time",
+			steps: []reserveStep{
+				{0, 10, 0},
+				{500 * time.Millisecond, 5, 0},
+				{500 * time.Millisecond, 1, 100 * time.Millisecond},
+			},
+		},
+		{
+			name: "batch above burst is capped",
+			steps: []reserveStep{
+				{0, 100, 0},
+				{0, 100, time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLimiter()
+			l.rate, l.burst = 10, 10
+			for i, step := range tt.steps {
+				if got := l.reserve(step.cost, start.Add(step.after)); got != step.want {
+					t.Errorf("step %d: wait %s, want %s", i, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l := newLimiter()
+	for i := 0; i < 100; i++ {
+		if wait := l.reserve(1000, time.Now()); wait != 0 {
+			t.Fatalf("unlimited limiter waits %s", wait)
+		}
+	}
+}
+
+func TestLimiterPause(t *testing.T) {
+	l := newLimiter()
+	l.pause(time.Hour)
+	if wait := l.reserve(1, time.Now()); wait < 59*time.Minute {
+		t.Errorf("wait after pause = %s, want about 1h", wait)
+	}
+	throttled, _, rateLimited := l.stats()
+	if throttled != 1 || rateLimited != 1 {
+		t.Errorf("stats = %d throttled, %d rate limited, want 1 and 1", throttled, rateLimited)
+	}
+}
+
+func TestLimiterCost(t *testing.T) {
+	l := newLimiter()
+	l.costs["eth_getLogs"] = 75
+	batch := []models.JSONRPCRequest{{Method: "eth_getLogs"}, {Method: "eth_blockNumber"}}
+	if got := l.cost(batch); got != 76 {
+		t.Errorf("batch cost = %v, want 76", got)
+	}
+	if got := l.cost(models.JSONRPCRequest{Method: "eth_getLogs"}); got != 75 {
+		t.Errorf("request cost = %v, want 75", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", _defaultRetryAfter},
+		{"3", 3 * time.Second},
+		{"0", 0},
+		{"soon", _defaultRetryAfter},
+		{"Mon, 01 Jan 2001 00:00:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		if got := retryAfter(header); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package jsonrpc
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// _ewmaAlpha — вес нового наблюдения в скользящих средних задержки и доли ошибок.
+	_ewmaAlpha = 0.2
+	// _defaultLatency используется, пока по эндпоинту нет наблюдений.
+	_defaultLatency = 100 * time.Millisecond
+)
+
+// ProviderHealth — состояние эндпоинта для админского API.
+type ProviderHealth struct {
+	Name                string       `json:"name"`
+	URL                 string       `json:"url"`
+	Score               float64      `json:"score"`
+	LatencyMs           float64      `json:"latencyMs"`
+	ErrorRate           float64      `json:"errorRate"`
+	Requests            uint64       `json:"requests"`
+	Failures            uint64       `json:"failures"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	Breaker             BreakerState `json:"breaker"`
+	BreakerTrips        uint64       `json:"breakerTrips"`
+	LastError           string       `json:"lastError,omitempty"`
+	LastFailure         time.Time    `json:"lastFailure,omitempty"`
+	Throttled           uint64       `json:"throttled"`
+	ThrottleWaitMs      float64      `json:"throttleWaitMs"`
+	RateLimited         uint64       `json:"rateLimited"`
+}
+
+// endpoint хранит статистику одного провайдера пула.
+type endpoint struct {
+	provider *Provider
+	breaker  *breaker
+
+	mu                  sync.Mutex
+	latency             time.Duration
+	errorRate           float64
+	requests            uint64
+	failures            uint64
+	consecutiveFailures int
+	lastError           string
+	lastFailure         time.Time
+}
+
+func newEndpoint(provider *Provider, breaker *breaker) *endpoint {
+	return &endpoint{provider: provider, breaker: breaker, latency: _defaultLatency}
+}
+
+// record учитывает исход запроса, отправленного с билетом пробы probe.
+// Сбоем эндпоинта считаются только ошибки, о которых говорит endpointFailure;
+// остальные ошибки запоминаются в lastError, но не влияют на предохранитель и score.
+func (e *endpoint) record(latency time.Duration, err error, probe uint64) {
+	failed := endpointFailure(err)
+	e.breaker.record(failed, time.Now(), probe)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	if err != nil {
+		e.lastError = err.Error()
+		e.lastFailure = time.Now()
+	}
+	outcome := 0.0
+	switch {
+	case failed:
+		outcome = 1
+		e.failures++
+		e.consecutiveFailures++
+	case err == nil:
+		e.consecutiveFailures = 0
+		e.latency = time.Duration(_ewmaAlpha*float64(latency) + (1-_ewmaAlpha)*float64(e.latency))
+	}
+	e.errorRate = _ewmaAlpha*outcome + (1-_ewmaAlpha)*e.errorRate
+}
+
+// endpointFailure сообщает, говорит ли err о неисправности самого эндпоинта:
+// сетевой сбой или таймаут, испорченный ответ, 408 и 5xx. Отказ в запросе (4xx),
+// 429, уже учтённый лимитером, и ошибки JSON-RPC узла к сбоям не относятся.
+func endpointFailure(err error) bool {
+	var transportErr *TransportError
+	var decodeErr *DecodeError
+	var statusErr *HTTPStatusError
+	switch {
+	case errors.As(err, &transportErr), errors.As(err, &decodeErr):
+		return true
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode == http.StatusRequestTimeout || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// observe учитывает задержку запроса, прерванного без ошибки со стороны эндпоинта.
+func (e *endpoint) observe(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.latency = time.Duration(_ewmaAlpha*float64(latency) + (1-_ewmaAlpha)*float64(e.latency))
+}
+
+// score — чем меньше, тем здоровее эндпоинт: задержка, умноженная на штраф
+// за ошибки; эндпоинты с разомкнутым предохранителем идут в конец.
+func (e *endpoint) score(now time.Time) float64 {
+	state, _ := e.breaker.snapshot(now)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.scoreLocked(state)
+}
+
+func (e *endpoint) scoreLocked(state BreakerState) float64 {
+	score := float64(e.latency.Milliseconds()+1) * (1 + 10*e.errorRate)
+	if state != BreakerClosed {
+		score += math.MaxInt32
+	}
+	return score
+}
+
+func (e *endpoint) health(now time.Time) ProviderHealth {
+	throttled, waited, rateLimited := e.provider.limiter.stats()
+	state, trips := e.breaker.snapshot(now)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return ProviderHealth{
+		Name:                e.provider.Name(),
+		URL:                 e.provider.String(),
+		Score:               e.scoreLocked(state),
+		LatencyMs:           float64(e.latency) / float64(time.Millisecond),
+		ErrorRate:           e.errorRate,
+		Requests:            e.requests,
+		Failures:            e.failures,
+		ConsecutiveFailures: e.consecutiveFailures,
+		Breaker:             state,
+		BreakerTrips:        trips,
+		LastError:           e.lastError,
+		LastFailure:         e.lastFailure,
+		Throttled:           throttled,
+		ThrottleWaitMs:      float64(waited) / float64(time.Millisecond),
+		RateLimited:         rateLimited,
+	}
+}
+
+// ranked возвращает эндпоинты от самого здорового к наименее здоровому.
+func ranked(endpoints []*endpoint) []*endpoint {
+	now := time.Now()
+	scores := make(map[*endpoint]float64, len(endpoints))
+	for _, e := range endpoints {
+		scores[e] = e.score(now)
+	}
+	result := make([]*endpoint, len(endpoints))
+	copy(result, endpoints)
+	sort.SliceStable(result, func(i, j int) bool {
+		return scores[result[i]] < scores[result[j]]
+	})
+	return result
+}
@@ -0,0 +1,178 @@
+package jsonrpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthStyle определяет, как ключ API передаётся провайдеру.
+type AuthStyle string
+
+const (
+	// AuthPath — ключ подставляется в URL вместо {key} (GetBlock, Infura, Alchemy).
+	AuthPath AuthStyle = "path"
+	// AuthHeader — ключ передаётся в заголовке AuthHeader.
+	AuthHeader AuthStyle = "header"
+	// AuthBearer — ключ передаётся как Authorization: Bearer <key>.
+	AuthBearer AuthStyle = "bearer"
+	// AuthNone — собственный узел без аутентификации.
+	AuthNone AuthStyle = "none"
+
+	_keyPlaceholder    = "{key}"
+	_redactedKey       = "***"
+	_defaultAuthHeader = "X-API-Key"
+)
+
+// Provider описывает эндпоинт JSON-RPC: шаблон URL, заголовки и способ аутентификации.
+type Provider struct {
+	name       string
+	url        string
+	headers    map[string]string
+	auth       AuthStyle
+	authHeader string
+	apiKey     string
+	limiter    *limiter
+}
+
+// ProviderOption -.
+type ProviderOption func(*Provider)
+
+// Name задаёт имя провайдера для логов и метрик.
+func Name(name string) ProviderOption {
+	return func(p *Provider) {
+		p.name = name
+	}
+}
+
+// Headers задаёт дополнительные заголовки каждого запроса.
+func Headers(headers map[string]string) ProviderOption {
+	return func(p *Provider) {
+		for k, v := range headers {
+			p.headers[k] = v
+		}
+	}
+}
+
+// Auth задаёт способ передачи ключа. header — имя заголовка для AuthHeader.
+func Auth(style AuthStyle, apiKey string, header string) ProviderOption {
+	return func(p *Provider) {
+		p.auth = style
+		p.apiKey = apiKey
+		if header != "" {
+			p.authHeader = header
+		}
+	}
+}
+
+// RateLimit ограничивает исходящие запросы: rate единиц стоимости в секунду
+// с запасом burst. rate 0 отключает ограничение.
+func RateLimit(rate float64, burst int) ProviderOption {
+	return func(p *Provider) {
+		p.limiter.rate = rate
+		p.limiter.burst = float64(burst)
+	}
+}
+
+// MethodCosts задаёт стоимость методов в единицах лимита (compute units);
+// остальные методы стоят 1.
+func MethodCosts(costs map[string]float64) ProviderOption {
+	return func(p *Provider) {
+		for method, cost := range costs {
+			p.limiter.costs[method] = cost
+		}
+	}
+}
+
+// NewProvider создаёт провайдера по шаблону URL. В режиме AuthPath шаблон
+// должен содержать {key}.
+func NewProvider(urlTemplate string, opts ...ProviderOption) (*Provider, error) {
+	p := &Provider{
+		url:        urlTemplate,
+		headers:    make(map[string]string),
+		auth:       AuthNone,
+		authHeader: _defaultAuthHeader,
+		limiter:    newLimiter(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.limiter.rate < 0 {
+		return nil, fmt.Errorf("negative rate limit %v", p.limiter.rate)
+	}
+	if p.limiter.burst <= 0 {
+		p.limiter.burst = max(p.limiter.rate, 1)
+	}
+
+	switch p.auth {
+	case AuthPath:
+		if !strings.Contains(p.url, _keyPlaceholder) {
+			return nil, fmt.Errorf("provider url %q has no %s placeholder", p.url, _keyPlaceholder)
+		}
+	case AuthHeader, AuthBearer, AuthNone:
+	default:
+		return nil, fmt.Errorf("unknown auth style %q", p.auth)
+	}
+	if p.auth != AuthNone && p.apiKey == "" {
+		return nil, errors.New("api key is required for auth style " + string(p.auth))
+	}
+	if _, err := url.Parse(p.endpoint(_redactedKey)); err != nil {
+		return nil, err
+	}
+	if p.name == "" {
+		p.name = p.String()
+	}
+	return p, nil
+}
+
+// Name -.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// String возвращает URL без ключа, пригодный для логов.
+func (p *Provider) String() string {
+	return p.endpoint(_redactedKey)
+}
+
+func (p *Provider) endpoint(key string) string {
+	if p.auth != AuthPath {
+		return strings.ReplaceAll(p.url, _keyPlaceholder, "")
+	}
+	return strings.ReplaceAll(p.url, _keyPlaceholder, key)
+}
+
+// apply добавляет к запросу заголовки и аутентификацию провайдера.
+func (p *Provider) apply(req *http.Request) {
+	for k, v := range p.header() {
+		req.Header[k] = v
+	}
+}
+
+func (p *Provider) header() http.Header {
+	header := make(http.Header, len(p.headers)+1)
+	for k, v := range p.headers {
+		header.Set(k, v)
+	}
+	switch p.auth {
+	case AuthHeader:
+		header.Set(p.authHeader, p.apiKey)
+	case AuthBearer:
+		header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	return header
+}
+
+// redact убирает ключ из ошибок net/http, которые содержат полный URL запроса.
+func (p *Provider) redact(err error) error {
+	if err == nil || p.apiKey == "" {
+		return err
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = strings.ReplaceAll(urlErr.URL, p.apiKey, _redactedKey)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const _testKey = "s3cr3t-key"
+
+func TestProviderAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		auth       ProviderOption
+		wantPath   string
+		wantHeader string
+		wantValue  string
+	}{
+		{name: "path", template: "/v1/{key}", auth: Auth(AuthPath, _testKey, ""), wantPath: "/v1/" + _testKey},
+		{name: "header", template: "/v1", auth: Auth(AuthHeader, _testKey, ""), wantPath: "/v1", wantHeader: _defaultAuthHeader, wantValue: _testKey},
+		{name: "custom header", template: "/v1", auth: Auth(AuthHeader, _testKey, "X-Token"), wantPath: "/v1", wantHeader: "X-Token", wantValue: _testKey},
+		{name: "bearer", template: "/v1", auth: Auth(AuthBearer, _testKey, ""), wantPath: "/v1", wantHeader: "Authorization", wantValue: "Bearer " + _testKey},
+		{name: "none", template: "/v1", auth: Auth(AuthNone, "", ""), wantPath: "/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+			}))
+			defer server.Close()
+
+			provider, err := NewProvider(server.URL+tt.template, tt.auth, Headers(map[string]string{"X-Extra": "1"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(provider.String(), _testKey) || strings.Contains(provider.Name(), _testKey) {
+				t.Errorf("provider name %q leaks the key", provider.Name())
+			}
+			client := &Client{httpClient: server.Client()}
+			var response map[string]any
+			if err := client.postTo(context.Background(), provider, []byte(`{}`), &response); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.URL.Path != tt.wantPath {
+				t.Errorf("path %q, want %q", got.URL.Path, tt.wantPath)
+			}
+			if got.Header.Get("X-Extra") != "1" {
+				t.Error("extra header not sent")
+			}
+			for _, name := range []string{_defaultAuthHeader, "X-Token", "Authorization"} {
+				want := ""
+				if name == tt.wantHeader {
+					want = tt.wantValue
+				}
+				if value := got.Header.Get(name); value != want {
+					t.Errorf("header %s = %q, want %q", name, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewProviderRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		auth     ProviderOption
+	}{
+		{name: "path without placeholder", template: "https://node.example/v1", auth: Auth(AuthPath, _testKey, "")},
+		{name: "header without key", template: "https://node.example/v1", auth: Auth(AuthHeader, "", "")},
+		{name: "bearer without key", template: "https://node.example/v1", auth: Auth(AuthBearer, "", "")},
+		{name: "unknown style", template: "https://node.example/v1", auth: Auth("query", _testKey, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProvider(tt.template, tt.auth)
+			if err == nil {
+				t.Fatal("provider accepted")
+			}
+			if strings.Contains(err.Error(), _testKey) {
+				t.Errorf("error %q leaks the key", err)
+			}
+		})
+	}
+}
+
+// TestProviderErrorsRedactKey: ошибки net/http содержат полный URL запроса,
+// ключ из пути в них заменяется на ***.
+func TestProviderErrorsRedactKey(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := server.URL
+	server.Close() // соединения к закрытому серверу отклоняются
+
+	provider, err := NewProvider(endpoint+"/v1/{key}", Auth(AuthPath, _testKey, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &Client{httpClient: http.DefaultClient}
+	err = client.postTo(context.Background(), provider, []byte(`{}`), nil)
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatalf("error %v, want TransportError", err)
+	}
+	if strings.Contains(err.Error(), _testKey) {
+		t.Errorf("error %q leaks the key", err)
+	}
+	if !strings.Contains(err.Error(), "/v1/"+_redactedKey) {
+		t.Errorf("error %q does not show the redacted URL", err)
+	}
+
+	// Ключ из пути не попадает и в ошибку построения запроса.
+	bad, err := NewProvider("http://node.example/v1/{key}", Auth(AuthPath, "bad key\x7f", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.postTo(context.Background(), bad, []byte(`{}`), nil)
+	if err == nil || strings.Contains(err.Error(), "bad key") {
+		t.Errorf("request error %v, want one without the key", err)
+	}
+}
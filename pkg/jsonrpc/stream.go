@@ -0,0 +1,134 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	models "eth_bal/internal/models"
+	"fmt"
+)
+
+// BatchItem — элемент пакетного ответа, разобранного потоково. Result — значение,
+// созданное BatchResults.newResult, или nil, если узел вернул ошибку. Bytes —
+// размер поля result в ответе.
+type BatchItem struct {
+	ID     int64
+	Result any
+	Error  *models.RPCError
+	Bytes  int64
+}
+
+// BatchResults принимает ответ SendBatchStream. Ответ не буферизуется целиком и
+// не проходит через json.RawMessage: поле result каждого элемента сразу
+// декодируется в значение из newResult (обычно указатель на компактную структуру).
+// Сам json.Decoder при Decode всё же читает значение result целиком в свой буфер,
+// поэтому пик памяти ограничен размером самого крупного блока, а не всего пакета.
+type BatchResults struct {
+	newResult func() any
+	Items     []BatchItem
+}
+
+func NewBatchResults(newResult func() any) *BatchResults {
+	return &BatchResults{newResult: newResult}
+}
+
+// SendBatchStream отправляет пакет и разбирает ответ потоково в results.
+func (c *Client) SendBatchStream(ctx context.Context, requests []models.JSONRPCRequest, results *BatchResults) error {
+	return c.post(ctx, requests, results)
+}
+
+func (r *BatchResults) decode(dec *json.Decoder) error {
+	r.Items = r.Items[:0]
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('['):
+	case json.Delim('{'):
+		// Некоторые провайдеры отвечают на весь пакет одним объектом с ошибкой.
+		item, err := r.decodeItem(dec)
+		if err != nil {
+			return err
+		}
+		if item.Error != nil {
+			return &RPCError{ID: item.ID, Method: "batch", Code: item.Error.Code, Message: item.Error.Message}
+		}
+		return fmt.Errorf("expected batch response array, got object")
+	default:
+		return fmt.Errorf("expected batch response array, got %v", tok)
+	}
+	for dec.More() {
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
+		}
+		item, err := r.decodeItem(dec)
+		if err != nil {
+			return err
+		}
+		r.Items = append(r.Items, item)
+	}
+	return expectDelim(dec, ']')
+}
+
+// decodeItem разбирает поля одного ответа; открывающая скобка уже прочитана.
+func (r *BatchResults) decodeItem(dec *json.Decoder) (BatchItem, error) {
+	var item BatchItem
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return item, err
+		}
+		switch tok {
+		case "id":
+			err = dec.Decode(&item.ID)
+		case "error":
+			err = dec.Decode(&item.Error)
+		case "result":
+			start := dec.InputOffset()
+			result := r.newResult()
+			err = dec.Decode(result)
+			item.Result = result
+			item.Bytes = dec.InputOffset() - start
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return item, err
+		}
+	}
+	if item.Error != nil {
+		item.Result = nil
+	}
+	return item, expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// skipValue пропускает очередное значение, не сохраняя его.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	models "eth_bal/internal/models"
+	"eth_bal/pkg/log"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	_wsMinBackoff = 500 * time.Millisecond
+	_wsMaxBackoff = 30 * time.Second
+)
+
+// HeadSubscriber подписывается на eth_subscribe("newHeads") по WebSocket и
+// переподключается с повторной подпиской после обрыва соединения. Соединение,
+// по которому дольше readTimeout не пришло ни одного сообщения, считается
+// оборванным: так обнаруживаются полуоткрытые TCP-соединения.
+type HeadSubscriber struct {
+	provider    *Provider
+	readTimeout time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+// HeadSubscriberOption -.
+type HeadSubscriberOption func(*HeadSubscriber)
+
+// ReadTimeout задаёт, сколько ждать очередного сообщения подписки до
+// переподключения; 0 — ждать без ограничения.
+func ReadTimeout(timeout time.Duration) HeadSubscriberOption {
+	return func(s *HeadSubscriber) {
+		s.readTimeout = timeout
+	}
+}
+
+func NewHeadSubscriber(provider *Provider, opts ...HeadSubscriberOption) *HeadSubscriber {
+	s := &HeadSubscriber{
+		provider:   provider,
+		minBackoff: _wsMinBackoff,
+		maxBackoff: _wsMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type subscriptionMessage struct {
+	ID     *int64           `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Result json.RawMessage  `json:"result,omitempty"`
+	Error  *models.RPCError `json:"error,omitempty"`
+	Params *struct {
+		Subscription string       `json:"subscription"`
+		Result       models.Block `json:"result"`
+	} `json:"params,omitempty"`
+}
+
+// Run доставляет заголовки новых блоков в onHead, пока не отменён ctx.
+// onHead вызывается последовательно из одной горутины.
+func (s *HeadSubscriber) Run(ctx context.Context, onHead func(models.Block)) {
+	backoff := s.minBackoff
+	for ctx.Err() == nil {
+		start := time.Now()
+		err := s.subscribe(ctx, onHead)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > s.maxBackoff {
+			// Соединение жило долго — начинаем отсчёт задержек заново.
+			backoff = s.minBackoff
+		}
+		log.Logger.WithFields(logrus.Fields{
+			"provider": s.provider.Name(),
+			"error":    err,
+			"retry_in": backoff,
+		}).Warn("newHeads subscription lost, resubscribing")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+func (s *HeadSubscriber) subscribe(ctx context.Context, onHead func(models.Block)) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Закрытие соединения прерывает блокирующее чтение при отмене ctx.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	request := models.JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "eth_subscribe",
+		Params:  []any{"newHeads"},
+		ID:      1,
+	}
+	if err := websocket.JSON.Send(conn, request); err != nil {
+		return err
+	}
+
+	var subscriptionID string
+	for {
+		if s.readTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(s.readTimeout)); err != nil {
+				return err
+			}
+		}
+		var msg subscriptionMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return err
+		}
+		switch {
+		case msg.ID != nil && *msg.ID == request.ID:
+			if msg.Error != nil {
+				return &RPCError{ID: request.ID, Method: request.Method, Code: msg.Error.Code, Message: msg.Error.Message}
+			}
+			if err := json.Unmarshal(msg.Result, &subscriptionID); err != nil {
+				return err
+			}
+			log.Logger.WithFields(logrus.Fields{
+				"provider":     s.provider.Name(),
+				"subscription": subscriptionID,
+			}).Info("Subscribed to newHeads")
+		case msg.Method == "eth_subscription" && msg.Params != nil:
+			if subscriptionID == "" || msg.Params.Subscription != subscriptionID {
+				continue
+			}
+			onHead(msg.Params.Result)
+		}
+	}
+}
+
+func (s *HeadSubscriber) dial(ctx context.Context) (*websocket.Conn, error) {
+	location, err := url.Parse(s.provider.endpoint(s.provider.apiKey))
+	if err != nil {
+		return nil, s.provider.redact(err)
+	}
+	origin := &url.URL{Scheme: "http", Host: location.Host}
+	config := &websocket.Config{
+		Location: location,
+		Origin:   origin,
+		Version:  websocket.ProtocolVersionHybi13,
+		Header:   s.provider.header(),
+	}
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		// DialError печатает полный URL, в котором может быть ключ.
+		var dialErr *websocket.DialError
+		if errors.As(err, &dialErr) {
+			return nil, fmt.Errorf("websocket dial %s: %w", s.provider.String(), dialErr.Err)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"context"
+	"eth_bal/internal/models"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// TestHeadSubscriberResubscribes проверяет подписку, переподключение после
+// закрытого соединения и после соединения, по которому перестали приходить
+// сообщения (полуоткрытое TCP-соединение).
+func TestHeadSubscriberResubscribes(t *testing.T) {
+	var connections atomic.Int32
+	stop := make(chan struct{})
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		n := connections.Add(1)
+		var request models.JSONRPCRequest
+		if err := websocket.JSON.Receive(conn, &request); err != nil || request.Method != "eth_subscribe" {
+			t.Errorf("connection %d: expected eth_subscribe, got %+v (%v)", n, request, err)
+			return
+		}
+		subscription := "0xsub" + string(rune('0'+n))
+		websocket.JSON.Send(conn, map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": subscription})
+		head := func(sub string, number int32) {
+			websocket.JSON.Send(conn, map[string]any{
+				"jsonrpc": "2.0",
+				"method":  "eth_subscription",
+				"params": map[string]any{
+					"subscription": sub,
+					"result":       map[string]any{"number": models.BlockNumber(number).Hex(), "hash": "0xh"},
+				},
+			})
+		}
+		head("0xother", 100) // чужая подписка игнорируется
+		head(subscription, n)
+		if n == 1 {
+			return // первое соединение обрывается сервером
+		}
+		<-stop // второе молчит, не закрываясь; третье живёт до конца теста
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(stop) })
+
+	provider, err := NewProvider("ws"+strings.TrimPrefix(server.URL, "http"), Auth(AuthNone, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscriber := NewHeadSubscriber(provider, ReadTimeout(200*time.Millisecond))
+	subscriber.minBackoff = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	heads := make(chan string, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		subscriber.Run(ctx, func(block models.Block) { heads <- block.Number })
+	}()
+
+	for _, want := range []string{"0x1", "0x2", "0x3"} {
+		select {
+		case got := <-heads:
+			if got != want {
+				t.Fatalf("head %s, want %s", got, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("no head %s after %d connections", want, connections.Load())
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if n := connections.Load(); n < 3 {
+		t.Errorf("%d connections, want at least 3", n)
+	}
+}
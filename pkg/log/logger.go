@@ -1,6 +1,8 @@
 package log
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -10,7 +12,8 @@ import (
 var Logger *logrus.Logger
 
 func init() {
-	if err := godotenv.Load(); err != nil {
+	// Без файла .env переменные берутся из окружения процесса.
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		logrus.Fatalf("Error loading .env file: %v", err)
 	}
 